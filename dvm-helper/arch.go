@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
+	"github.com/howtowhale/dvm/dvm-helper/internal/platform"
+	"github.com/pkg/errors"
+)
+
+// Docker versions for the current architecture are installed in bin/docker,
+// and versions for other architectures, e.g. for qemu/binfmt users, in bin/docker-<arch>
+func getArchVersionsDir(arch string) string {
+	if arch == "" || arch == platform.Current().Arch {
+		return filepath.Join(opts.DvmDir, "bin", "docker")
+	}
+	return filepath.Join(opts.DvmDir, "bin", "docker-"+arch)
+}
+
+// Docker only publishes clients for some architectures. This is only checked when
+// downloading, so that installed versions can still be listed and used on other hosts.
+func checkDownloadPlatform() error {
+	if opts.Platform.Arch == "" {
+		return errors.Errorf("Docker does not publish clients for %s, specify the architecture with --arch", runtime.GOARCH)
+	}
+	_, err := platform.Parse(opts.Platform.OS, opts.Platform.Arch)
+	return err
+}
+
+func validateDownloadPlatform() {
+	if err := checkDownloadPlatform(); err != nil {
+		die("Invalid platform.", err, retCodeInvalidArgument)
+	}
+}
+
+// List the other architectures which have Docker versions installed
+func getInstalledArches() []string {
+	archDirs, _ := filepath.Glob(filepath.Join(opts.DvmDir, "bin", "docker-*"))
+
+	var results []string
+	for _, archDir := range archDirs {
+		if fi, err := os.Stat(archDir); err != nil || !fi.IsDir() {
+			continue
+		}
+
+		arch := strings.TrimPrefix(filepath.Base(archDir), "docker-")
+		if _, err := platform.Parse("", arch); err != nil {
+			writeDebug("Skipping %s: %s", archDir, err)
+			continue
+		}
+		results = append(results, arch)
+	}
+
+	sort.Strings(results)
+	return results
+}
+
+// List the Docker versions installed for another architecture
+func getInstalledArchVersions(arch string, pattern string) []dockerversion.Version {
+	versionDirs, _ := filepath.Glob(filepath.Join(getArchVersionsDir(arch), pattern))
+
+	var results []dockerversion.Version
+	for _, versionDir := range versionDirs {
+		results = append(results, dockerversion.Parse(filepath.Base(versionDir)))
+	}
+
+	dockerversion.Sort(results)
+	return results
+}
+
+// Check if a docker client was installed for another architecture
+func isOtherArchPath(dockerPath string) bool {
+	for _, arch := range getInstalledArches() {
+		if isPathWithin(dockerPath, getArchVersionsDir(arch)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check if the current docker client was installed for the architecture in opts.Platform
+func isCurrentDockerArch() bool {
+	currentPath, _ := getCurrentDockerPath()
+	if opts.Platform.IsCurrentArch() {
+		return !isOtherArchPath(currentPath)
+	}
+	return isPathWithin(currentPath, getVersionsDir())
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/howtowhale/dvm/dvm-helper/internal/platform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetArchVersionsDir(t *testing.T) {
+	setupTestDvmDir(t)
+
+	assert.Equal(t, filepath.Join(opts.DvmDir, "bin", "docker"), getArchVersionsDir(""))
+	assert.Equal(t, filepath.Join(opts.DvmDir, "bin", "docker"), getArchVersionsDir(platform.Current().Arch))
+	assert.Equal(t, filepath.Join(opts.DvmDir, "bin", "docker-s390x"), getArchVersionsDir("s390x"))
+}
+
+func TestIsOtherArchPath(t *testing.T) {
+	dvmDir := setupTestDvmDir(t)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(getArchVersionsDir("s390x"), "20.10.24"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dvmDir, "bin", "docker-bogus"), 0755))
+
+	assert.Equal(t, []string{"s390x"}, getInstalledArches())
+	assert.True(t, isOtherArchPath(filepath.Join(getArchVersionsDir("s390x"), "20.10.24", "docker")))
+	assert.False(t, isOtherArchPath(filepath.Join(getArchVersionsDir(""), "20.10.24", "docker")))
+	assert.False(t, isOtherArchPath("/usr/local/bin/docker"))
+}
+
+func TestCheckDownloadPlatform(t *testing.T) {
+	oldPlatform := opts.Platform
+	defer func() { opts.Platform = oldPlatform }()
+
+	opts.Platform = platform.Platform{OS: "linux", Arch: "x86_64"}
+	assert.NoError(t, checkDownloadPlatform())
+
+	// Docker doesn't publish clients for every host, e.g. riscv64
+	opts.Platform = platform.Platform{OS: "linux", Arch: ""}
+	assert.Error(t, checkDownloadPlatform(), "Downloading should require an architecture that Docker publishes clients for")
+
+	// Installed versions can still be found
+	assert.Equal(t, getArchVersionsDir(platform.Current().Arch), getVersionsDir())
+}
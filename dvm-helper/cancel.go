@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Cancelled when the user presses Ctrl-C or dvm is terminated, so that downloads stop and clean up
+var appCtx = context.Background()
+
+// How long a command has to stop after it is cancelled, before dvm exits anyway
+const cancelGracePeriod = 2 * time.Second
+
+// Cleanup to run when dvm exits early with die, e.g. removing partial downloads
+var exitHandlers []func()
+var exitHandlersMu sync.Mutex
+
+// Cancel appCtx on the first SIGINT or SIGTERM. Later signals are not caught, so a second Ctrl-C exits immediately.
+// Not every command uses appCtx, e.g. running docker, so dvm exits when the command doesn't stop by itself.
+func handleSignals() {
+	var cancel context.CancelFunc
+	appCtx, cancel = context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)
+		writeDebug("Received %s, cancelling", sig)
+		cancel()
+
+		time.Sleep(cancelGracePeriod)
+		writeDebug("The command did not stop after it was cancelled, exiting")
+		runExitHandlers()
+		writeError("Cancelled.", nil)
+		os.Exit(retCodeCancelled)
+	}()
+}
+
+// Register cleanup that should run if dvm exits with die
+func onExit(handler func()) {
+	exitHandlersMu.Lock()
+	defer exitHandlersMu.Unlock()
+	exitHandlers = append(exitHandlers, handler)
+}
+
+func runExitHandlers() {
+	exitHandlersMu.Lock()
+	defer exitHandlersMu.Unlock()
+	for i := len(exitHandlers) - 1; i >= 0; i-- {
+		exitHandlers[i]()
+	}
+	exitHandlers = nil
+}
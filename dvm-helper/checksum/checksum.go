@@ -24,13 +24,17 @@ func CompareChecksum(filePath string, checksumPath string) (bool, error) {
 	return strings.Compare(knownChecksum, checksum) == 0, nil
 }
 
+// ParseChecksum reads the SHA256 checksum from the contents of a checksum file, e.g. <checksum> docker-1.10.3
+func ParseChecksum(contents string) string {
+	return strings.Split(strings.TrimSpace(contents), " ")[0]
+}
+
 func readChecksum(checksumPath string) (string, error) {
 	contents, err := ioutil.ReadFile(checksumPath)
 	if err != nil {
 		return "", err
 	}
-	checksum := strings.Split(string(contents), " ")[0]
-	return checksum, nil
+	return ParseChecksum(string(contents)), nil
 }
 
 func calculateChecksum(filePath string) (string, error) {
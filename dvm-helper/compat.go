@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
+	"github.com/howtowhale/dvm/dvm-helper/internal/dockercontext"
+	"github.com/pkg/errors"
+)
+
+const apiVersionEnvVar = "DOCKER_API_VERSION"
+
+// How long `dvm use --check-compat` waits for the docker daemon
+const useCompatTimeout = 3 * time.Second
+
+type compatOptions struct {
+	// Context is the docker context to check against, defaults to the current context.
+	Context string
+
+	// Timeout limits how long to wait for the docker daemon.
+	Timeout time.Duration
+
+	// SetAPIVersion exports DOCKER_API_VERSION so that a newer client can talk to an older daemon.
+	SetAPIVersion bool
+}
+
+func compat(options compatOptions) {
+	version, err := getCurrentDockerVersion()
+	if err != nil {
+		die("Unable to determine the current Docker version.", err, retCodeRuntimeError)
+	}
+
+	compatible, err := checkCompatibility(version, options)
+	if err != nil {
+		die("", err, retCodeRuntimeError)
+	}
+	if compatible {
+		writeInfo("Docker %s is compatible with the docker daemon.", version)
+	}
+}
+
+// checkCompatibility warns when the client speaks a newer API than the daemon,
+// optionally exporting DOCKER_API_VERSION so that the client downgrades its requests.
+func checkCompatibility(version dockerversion.Version, options compatOptions) (bool, error) {
+	clientAPIVersion := getClientAPIVersion(version)
+	if clientAPIVersion == "" {
+		return false, errors.Errorf("Unable to determine the API version of Docker %s", version)
+	}
+
+	store := getDockerContextStore()
+	contextName := options.Context
+	if contextName == "" {
+		contextName = store.Current()
+	}
+	dockerContext, err := store.Load(contextName)
+	if err != nil {
+		return false, err
+	}
+
+	serverAPIVersion, err := queryServerAPIVersion(dockerContext, options.Timeout)
+	if err != nil {
+		return false, err
+	}
+	writeDebug("Docker %s speaks API version %s and the %s docker context speaks %s", version, clientAPIVersion, dockerContext.Name, serverAPIVersion)
+
+	compatible, pinAPIVersion := resolveCompatibility(clientAPIVersion, serverAPIVersion, options.SetAPIVersion)
+	if pinAPIVersion != "" {
+		setEnvironmentVariable(apiVersionEnvVar, pinAPIVersion)
+		writeInfo("Set %s=%s to match the docker daemon at %s.", apiVersionEnvVar, pinAPIVersion, dockerContext.Endpoint.Host)
+	}
+	if compatible {
+		return true, nil
+	}
+
+	writeWarning("Docker %s speaks API version %s, which is newer than API version %s spoken by the docker daemon at %s.",
+		version, clientAPIVersion, serverAPIVersion, dockerContext.Endpoint.Host)
+	writeWarning("Run `dvm compat --set-api-version` to set %s=%s, or `dvm detect` to use a matching client.", apiVersionEnvVar, serverAPIVersion)
+	return false, nil
+}
+
+// resolveCompatibility decides if a client can talk to a daemon. An incompatible client is
+// made compatible by pinning DOCKER_API_VERSION to the server's API version, when setAPIVersion is set.
+func resolveCompatibility(clientAPIVersion string, serverAPIVersion string, setAPIVersion bool) (compatible bool, pinAPIVersion string) {
+	if dockerversion.CompareAPIVersions(clientAPIVersion, serverAPIVersion) <= 0 {
+		return true, ""
+	}
+	if setAPIVersion {
+		return true, serverAPIVersion
+	}
+	return false, ""
+}
+
+// getClientAPIVersion reads the API version from the installed client, rather than
+// DOCKER_API_VERSION which may have been pinned for a previous daemon.
+// Falls back to the API version known for the release.
+func getClientAPIVersion(version dockerversion.Version) string {
+	if dockerPath := getVersionDockerPath(version); dockerPath != "" {
+		if info, err := inspectDockerClient(dockerPath); err == nil && info.APIVersion != "" {
+			return info.APIVersion
+		}
+	}
+	return version.APIVersion()
+}
+
+// queryServerAPIVersion asks the daemon for its API version, first through /_ping
+// and then through /version for daemons which don't report it when pinged.
+func queryServerAPIVersion(dockerContext dockercontext.Context, timeout time.Duration) (string, error) {
+	docker, err := dockerContext.NewClient()
+	if err != nil {
+		return "", errors.Wrap(err, "Cannot build a docker client")
+	}
+	defer docker.Close()
+
+	ctx := appCtx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ping, err := docker.Ping(ctx)
+	if err == nil && ping.APIVersion != "" {
+		return ping.APIVersion, nil
+	}
+	writeDebug("Unable to read the API version from /_ping, querying /version")
+
+	versionResult, err := docker.ServerVersion(ctx)
+	if err == context.DeadlineExceeded {
+		return "", errors.Errorf("The docker daemon for the %s docker context did not respond within %s", dockerContext.Name, timeout)
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "Unable to query docker version for the %s docker context", dockerContext.Name)
+	}
+	return versionResult.APIVersion, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveCompatibility(t *testing.T) {
+	testcases := []struct {
+		name          string
+		client        string
+		server        string
+		setAPIVersion bool
+		wantCompat    bool
+		wantPin       string
+	}{
+		{"same version", "1.41", "1.41", false, true, ""},
+		{"older client", "1.24", "1.41", false, true, ""},
+		{"older client is not pinned", "1.24", "1.41", true, true, ""},
+		{"minor versions compare numerically", "1.9", "1.24", false, true, ""},
+		{"newer client", "1.43", "1.41", false, false, ""},
+		{"newer client is pinned", "1.43", "1.41", true, true, "1.41"},
+		{"server with a v prefix", "1.41", "v1.41", false, true, ""},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			compatible, pin := resolveCompatibility(tc.client, tc.server, tc.setAPIVersion)
+			assert.Equal(t, tc.wantCompat, compatible)
+			assert.Equal(t, tc.wantPin, pin)
+		})
+	}
+}
+
+func TestGetClientAPIVersion(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The fake docker client is a shell script")
+	}
+
+	setupTestDvmDir(t)
+
+	// The client reports DOCKER_API_VERSION, when set, as its API version
+	installed := dockerversion.Parse("20.10.24")
+	script := "#!/bin/sh\necho '{\"Version\":\"20.10.24\",\"ApiVersion\":\"'${DOCKER_API_VERSION:-1.40}'\"}'\n"
+	require.NoError(t, os.MkdirAll(getVersionDir(installed), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(getVersionDir(installed), getBinaryName()), []byte(script), 0755))
+
+	t.Setenv(apiVersionEnvVar, "1.12")
+	assert.Equal(t, "1.40", getClientAPIVersion(installed), "The API version should be read from the client, ignoring DOCKER_API_VERSION")
+
+	missing := dockerversion.Parse("19.03.15")
+	assert.Equal(t, "1.40", getClientAPIVersion(missing), "The known API version should be used when the client is not installed")
+}
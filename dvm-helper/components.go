@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
+	"github.com/howtowhale/dvm/dvm-helper/internal/platform"
+)
+
+// Describe the components installed in a version directory, when it has more than the client
+func formatComponents(versionDir string, p platform.Platform) string {
+	components := dockerversion.InstalledComponents(versionDir, p)
+	if len(components) <= 1 {
+		return ""
+	}
+	return "\t" + strings.Join(components, ",")
+}
+
+// Let the user know when an installed version lacks requested components, since install won't replace it
+func warnMissingComponents(version dockerversion.Version) {
+	installed := make(map[string]bool)
+	for _, component := range dockerversion.InstalledComponents(getVersionDir(version), opts.Platform) {
+		installed[component] = true
+	}
+
+	var missing []string
+	for _, component := range opts.Components {
+		if !installed[component] {
+			missing = append(missing, component)
+		}
+	}
+
+	if len(missing) > 0 {
+		writeWarning("%s was installed without %s. Run `dvm uninstall %s` and install it again to add them.", version, strings.Join(missing, ", "), version.Name())
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
+	"github.com/howtowhale/dvm/dvm-helper/internal/dockercontext"
+)
+
+// Get the docker client configuration directory, e.g. ~/.docker
+func getDockerConfigDir() string {
+	if configDir := os.Getenv(dockerConfigEnvVar); configDir != "" {
+		return configDir
+	}
+	return filepath.Join(getUserHomeDir(), ".docker")
+}
+
+// Get the docker client configuration directory chosen by the user,
+// ignoring the isolated directory which dvm may have set
+func getUserDockerConfigDir() string {
+	if configDir := getOriginalEnvironmentVariable(dockerConfigEnvVar); configDir != "" {
+		return configDir
+	}
+	return filepath.Join(getUserHomeDir(), ".docker")
+}
+
+// Get the docker contexts, falling back to the user's configuration directory
+// when an isolated DOCKER_CONFIG has no contexts of its own
+func getDockerContextStore() dockercontext.Store {
+	configDir := getDockerConfigDir()
+	if _, err := os.Stat(filepath.Join(configDir, "contexts")); os.IsNotExist(err) {
+		if userConfigDir := getUserDockerConfigDir(); userConfigDir != configDir {
+			writeDebug("%s has no docker contexts, using the contexts in %s", configDir, userConfigDir)
+			configDir = userConfigDir
+		}
+	}
+	return dockercontext.NewStore(configDir)
+}
+
+func bind(context string, value string) {
+	validateContextName(context)
+	lockDvmDir()
+	if _, err := getDockerContextStore().Load(context); err != nil {
+		writeWarning("%s", err)
+	}
+
+	version := dockerversion.Parse(value)
+	bindingPath := getBindingPath(context)
+	if _, err := os.Stat(bindingPath); err == nil {
+		writeDebug("Overwriting existing binding.")
+	}
+
+	replaceFile(bindingPath, version.Name())
+	writeInfo("Bound the %s docker context to %s.", context, value)
+}
+
+func unbind(context string) {
+	validateContextName(context)
+	lockDvmDir()
+	bindingPath := getBindingPath(context)
+	if _, err := os.Stat(bindingPath); os.IsNotExist(err) {
+		writeWarning("The %s docker context is not bound.", context)
+		return
+	}
+
+	err := os.Remove(bindingPath)
+	if err != nil {
+		die("Unable to remove binding %s at %s.", err, retCodeRuntimeError, context, bindingPath)
+	}
+
+	writeInfo("Removed binding for the %s docker context", context)
+}
+
+func listBind() {
+	bindings := getBindings()
+	for context, version := range bindings {
+		writeInfo("\t%s -> %s", context, version)
+	}
+}
+
+func getBindings() map[string]string {
+	bindings, _ := filepath.Glob(getBindingPath("*"))
+
+	results := make(map[string]string)
+	for _, bindingPath := range bindings {
+		context := filepath.Base(bindingPath)
+		version, err := ioutil.ReadFile(bindingPath)
+		if err != nil {
+			writeDebug("Excluding binding %s: %s.", context, err)
+			continue
+		}
+
+		results[context] = string(version)
+	}
+
+	return results
+}
+
+// Docker context names can't contain path separators, so they can't escape the bindings directory
+var contextNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.+-]*$`)
+
+func isValidContextName(context string) bool {
+	return contextNameRegex.MatchString(context) && !strings.Contains(context, "..")
+}
+
+func validateContextName(context string) {
+	if !isValidContextName(context) {
+		die("%s is not a valid docker context name.", nil, retCodeInvalidArgument, context)
+	}
+}
+
+func getBindingPath(context string) string {
+	return filepath.Join(opts.DvmDir, "bind", context)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsValidContextName(t *testing.T) {
+	testcases := map[string]bool{
+		"default":    true,
+		"remote":     true,
+		"my-ctx_1.2": true,
+		"a":          true,
+		"":           false,
+		"..":         false,
+		"../escape":  false,
+		"a/b":        false,
+		`a\b`:        false,
+		"-leading":   false,
+		".hidden":    false,
+		"has..dots":  false,
+		"has space":  false,
+		"*":          false,
+	}
+
+	for name, want := range testcases {
+		assert.Equal(t, want, isValidContextName(name), "isValidContextName(%q)", name)
+	}
+}
+
+func TestBind_Overwrites(t *testing.T) {
+	dvmDir := setupTestDvmDir(t)
+	defer func() {
+		dvmDirLock.Release()
+		dvmDirLock = nil
+	}()
+	t.Setenv("DOCKER_CONFIG", dvmDir)
+
+	bind("remote", "20.10.24")
+	bind("remote", "19.03.15")
+	assert.Equal(t, map[string]string{"remote": "19.03.15"}, getBindings())
+
+	unbind("remote")
+	assert.Empty(t, getBindings())
+}
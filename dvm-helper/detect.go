@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
+	"github.com/howtowhale/dvm/dvm-helper/internal/dockercontext"
+	"github.com/pkg/errors"
+)
+
+const (
+	detectExact             = "exact"
+	detectHighestCompatible = "highest-compatible"
+	detectLowestCompatible  = "lowest-compatible"
+)
+
+type detectOptions struct {
+	// Context is the docker context to detect, defaults to the current context.
+	Context string
+
+	// Strategy selects the client version from the server version.
+	Strategy string
+
+	// Timeout limits how long to wait for the docker daemon, and to list the available versions.
+	Timeout time.Duration
+
+	// Fallback is the version to use when detection fails.
+	Fallback string
+
+	// Print only reports the detected version.
+	Print bool
+}
+
+func validateDetectStrategy(strategy string) {
+	switch strategy {
+	case detectExact, detectHighestCompatible, detectLowestCompatible:
+		return
+	}
+	die("Invalid detect strategy %s. Available values are %s, %s and %s.", nil, retCodeInvalidArgument,
+		strategy, detectExact, detectHighestCompatible, detectLowestCompatible)
+}
+
+func detect(options detectOptions) {
+	store := getDockerContextStore()
+	contextName := options.Context
+	if contextName == "" {
+		contextName = store.Current()
+	}
+	writeDebug("Using the %s docker context", contextName)
+
+	dockerContext, err := store.Load(contextName)
+	if err != nil {
+		die("", err, retCodeInvalidArgument)
+	}
+
+	version, err := detectVersion(dockerContext, options)
+	if err != nil {
+		if options.Fallback == "" {
+			die("", err, retCodeRuntimeError)
+		}
+
+		writeWarning("%s", err)
+		writeWarning("Falling back to %s", options.Fallback)
+		version = dockerversion.Parse(options.Fallback)
+	}
+	writeDebug("Detected client version: %s", version)
+
+	if options.Print {
+		writeInfo(version.String())
+		return
+	}
+
+	setEnvironmentVariable(versionEnvVar, version.String())
+
+	use(version)
+}
+
+func detectAllContexts(options detectOptions) {
+	contexts, err := getDockerContextStore().List()
+	if err != nil {
+		die("", err, retCodeRuntimeError)
+	}
+
+	useAfterInstall = false
+	for _, dockerContext := range contexts {
+		version, err := detectVersion(dockerContext, options)
+		if err != nil {
+			writeWarning("%s\t%s", dockerContext.Name, err)
+			continue
+		}
+		writeInfo("%s\t%s", dockerContext.Name, version)
+
+		if !options.Print && !version.IsEmpty() && !isVersionInstalled(version) {
+			install(version)
+		}
+	}
+}
+
+// detectVersion determines the client version for a docker context, either from its binding
+// or by querying the daemon.
+func detectVersion(dockerContext dockercontext.Context, options detectOptions) (dockerversion.Version, error) {
+	if binding, ok := getBindings()[dockerContext.Name]; ok {
+		writeDebug("The %s docker context is bound to %s", dockerContext.Name, binding)
+		return dockerversion.Parse(binding), nil
+	}
+
+	// The timeout covers both querying the daemon and listing the available versions
+	ctx := appCtx
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+
+	versionResult, err := queryServerVersion(ctx, dockerContext, options.Timeout)
+	if err != nil {
+		return dockerversion.Version{}, err
+	}
+
+	writeDebug("Queried /version and got Version: %s, API Version: %s", versionResult.Version, versionResult.APIVersion)
+
+	var version dockerversion.Version
+	switch options.Strategy {
+	case detectHighestCompatible:
+		version, err = findCompatibleVersion(ctx, versionResult.APIVersion, true)
+	case detectLowestCompatible:
+		version, err = findCompatibleVersion(ctx, versionResult.APIVersion, false)
+	default:
+		version, err = findExactVersion(ctx, versionResult)
+	}
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return dockerversion.Version{}, errors.Errorf("Unable to list the available Docker versions within %s", options.Timeout)
+	}
+	return version, err
+}
+
+func queryServerVersion(ctx context.Context, dockerContext dockercontext.Context, timeout time.Duration) (types.Version, error) {
+	docker, err := dockerContext.NewClient()
+	if err != nil {
+		return types.Version{}, errors.Wrap(err, "Cannot build a docker client")
+	}
+	defer docker.Close()
+
+	versionResult, err := docker.ServerVersion(ctx)
+	if err == context.DeadlineExceeded {
+		return types.Version{}, errors.Errorf("The docker daemon for the %s docker context did not respond within %s", dockerContext.Name, timeout)
+	}
+	return versionResult, errors.Wrapf(err, "Unable to query docker version for the %s docker context", dockerContext.Name)
+}
+
+// findExactVersion selects the client version which was released with the server.
+func findExactVersion(ctx context.Context, versionResult types.Version) (dockerversion.Version, error) {
+	version := dockerversion.Parse(versionResult.Version)
+
+	// Docker versions prior to 1.12 don't return a usable client version
+	// Lookup the client version from the API version
+	if version.IsEmpty() {
+		writeDebug("Attempting to lookup a client version for API version: %s", versionResult.APIVersion)
+
+		// api version -> client version range
+		oldVersionMap := map[string]string{
+			"1.23": "1.11.x",
+			"1.22": "1.10.x",
+			"1.21": "1.9.x",
+			"1.20": "1.8.x",
+			"1.19": "1.7.x",
+			"1.18": "1.6.x",
+		}
+		clientRange, found := oldVersionMap[versionResult.APIVersion]
+		if !found {
+			return dockerversion.Version{}, errors.Errorf("Unable to detect the proper client version for Docker API version %s", versionResult.APIVersion)
+		}
+
+		// Find the highest version that satisfies the client version range
+		availableVersions, err := listAvailableVersions(ctx, "", true)
+		if err != nil {
+			return dockerversion.Version{}, err
+		}
+		for i := len(availableVersions) - 1; i >= 0; i-- {
+			v := availableVersions[i]
+
+			if ok, _ := v.InRange(clientRange); ok {
+				version = v
+				break
+			}
+		}
+		if version.IsEmpty() {
+			return dockerversion.Version{}, errors.Errorf("Unable to detect the proper client version for %s", clientRange)
+		}
+	}
+
+	return version, nil
+}
+
+// findCompatibleVersion selects the highest or lowest client version whose API version is
+// no newer than the server's, preferring installed versions over available versions.
+func findCompatibleVersion(ctx context.Context, apiVersion string, highest bool) (dockerversion.Version, error) {
+	if apiVersion == "" {
+		return dockerversion.Version{}, errors.New("The docker daemon did not report its API version")
+	}
+
+	candidates := filterCompatibleVersions(getInstalledVersions("*"), apiVersion)
+	if len(candidates) == 0 {
+		writeDebug("No installed versions are compatible with API version %s, checking available versions", apiVersion)
+		availableVersions, err := listAvailableVersions(ctx, "", false)
+		if err != nil {
+			return dockerversion.Version{}, err
+		}
+		candidates = filterCompatibleVersions(availableVersions, apiVersion)
+	}
+	if len(candidates) == 0 {
+		return dockerversion.Version{}, errors.Errorf("Unable to find a client version compatible with Docker API version %s", apiVersion)
+	}
+
+	dockerversion.Sort(candidates)
+	if highest {
+		return candidates[len(candidates)-1], nil
+	}
+	return candidates[0], nil
+}
+
+func filterCompatibleVersions(versions []dockerversion.Version, apiVersion string) []dockerversion.Version {
+	var results []dockerversion.Version
+	for _, v := range versions {
+		if v.IsAlias() {
+			continue
+		}
+
+		clientAPIVersion := v.APIVersion()
+		if clientAPIVersion != "" && dockerversion.CompareAPIVersions(clientAPIVersion, apiVersion) <= 0 {
+			results = append(results, v)
+		}
+	}
+	return results
+}
@@ -0,0 +1,103 @@
+package dockerversion
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// apiVersions maps a client release (major.minor) to the highest Docker API version that it speaks.
+var apiVersions = map[string]string{
+	"1.6":   "1.18",
+	"1.7":   "1.19",
+	"1.8":   "1.20",
+	"1.9":   "1.21",
+	"1.10":  "1.22",
+	"1.11":  "1.23",
+	"1.12":  "1.24",
+	"1.13":  "1.25",
+	"17.3":  "1.26",
+	"17.4":  "1.28",
+	"17.5":  "1.29",
+	"17.6":  "1.30",
+	"17.7":  "1.31",
+	"17.9":  "1.32",
+	"17.10": "1.33",
+	"17.11": "1.34",
+	"17.12": "1.35",
+	"18.1":  "1.35",
+	"18.2":  "1.36",
+	"18.3":  "1.37",
+	"18.4":  "1.37",
+	"18.5":  "1.37",
+	"18.6":  "1.38",
+	"18.9":  "1.39",
+	"19.3":  "1.40",
+	"20.10": "1.41",
+	"23.0":  "1.42",
+	"24.0":  "1.43",
+	"25.0":  "1.44",
+	"26.0":  "1.45",
+	"26.1":  "1.45",
+	"27.0":  "1.46",
+	"27.1":  "1.46",
+	"27.2":  "1.47",
+	"27.3":  "1.47",
+	"27.4":  "1.47",
+	"27.5":  "1.47",
+	"28.0":  "1.48",
+	"28.1":  "1.49",
+	"28.2":  "1.50",
+	"28.3":  "1.51",
+}
+
+// patchAPIVersions overrides apiVersions for patch releases (major.minor.patch) which
+// raised the API version within a release line.
+var patchAPIVersions = map[string]string{
+	"17.3.1": "1.27",
+	"17.3.2": "1.27",
+}
+
+// APIVersion is the highest Docker API version spoken by the client, e.g. 1.41.
+// Returns an empty string when the API version is unknown.
+func (version Version) APIVersion() string {
+	if version.semver == nil {
+		return ""
+	}
+
+	patchKey := fmt.Sprintf("%d.%d.%d", version.semver.Major(), version.semver.Minor(), version.semver.Patch())
+	if apiVersion, ok := patchAPIVersions[patchKey]; ok {
+		return apiVersion
+	}
+
+	key := fmt.Sprintf("%d.%d", version.semver.Major(), version.semver.Minor())
+	return apiVersions[key]
+}
+
+// CompareAPIVersions compares Docker API versions a to b, e.g. 1.9 is less than 1.24:
+// -1 == a is less than b
+// 0 == a is equal to b
+// 1 == a is greater than b
+func CompareAPIVersions(a string, b string) int {
+	aParts := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bParts := strings.Split(strings.TrimPrefix(b, "v"), ".")
+
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aPart, bPart int
+		if i < len(aParts) {
+			aPart, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bPart, _ = strconv.Atoi(bParts[i])
+		}
+
+		if aPart < bPart {
+			return -1
+		}
+		if aPart > bPart {
+			return 1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,50 @@
+package dockerversion
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersion_APIVersion(t *testing.T) {
+	testcases := map[string]string{
+		"1.10.3":     "1.22",
+		"1.12.1":     "1.24",
+		"17.03.0-ce": "1.26",
+		"17.06.0-ce": "1.30",
+		"18.09.1":    "1.39",
+		"20.10.24":   "1.41",
+		"0.1.0":      "",
+		"system":     "",
+	}
+
+	for version, wantAPIVersion := range testcases {
+		assert.Equal(t, wantAPIVersion, Parse(version).APIVersion(), "Unexpected API version for %s", version)
+	}
+}
+
+func TestVersion_APIVersion_17_03Boundary(t *testing.T) {
+	testcases := []struct {
+		version        string
+		wantAPIVersion string
+	}{
+		{"17.03.0-ce", "1.26"},
+		{"17.03.1-ce", "1.27"},
+		{"17.03.2-ce", "1.27"},
+		{"17.04.0-ce", "1.28"},
+		{"17.04.0-ce-rc1", "1.28"},
+		{"17.05.0-ce", "1.29"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.version, func(t *testing.T) {
+			assert.Equal(t, tc.wantAPIVersion, Parse(tc.version).APIVersion())
+		})
+	}
+}
+
+func TestCompareAPIVersions(t *testing.T) {
+	assert.Equal(t, -1, CompareAPIVersions("1.9", "1.24"))
+	assert.Equal(t, 0, CompareAPIVersions("1.41", "v1.41"))
+	assert.Equal(t, 1, CompareAPIVersions("1.41", "1.4"))
+}
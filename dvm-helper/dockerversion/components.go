@@ -0,0 +1,121 @@
+package dockerversion
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/howtowhale/dvm/dvm-helper/internal/downloader"
+	"github.com/howtowhale/dvm/dvm-helper/internal/platform"
+	"github.com/pkg/errors"
+)
+
+// ClientComponent is the docker client, which is always installed.
+const ClientComponent = "client"
+
+// The binaries in the static release archives that make up each component.
+// The first binary is required, the rest are installed when present.
+// Older releases prefixed the bundled containerd and runc with docker-.
+var componentBinaries = map[string][][]string{
+	ClientComponent: {{"docker"}},
+	"dockerd":       {{"dockerd"}, {"docker-init"}, {"docker-proxy"}},
+	"containerd":    {{"containerd", "docker-containerd"}, {"containerd-shim", "docker-containerd-shim"}, {"containerd-shim-runc-v2"}},
+	"runc":          {{"runc", "docker-runc"}},
+	"ctr":           {{"ctr", "docker-containerd-ctr"}},
+}
+
+// Components lists the components that can be installed, in the order that they are displayed.
+func Components() []string {
+	return []string{ClientComponent, "dockerd", "containerd", "runc", "ctr"}
+}
+
+// ParseComponents reads a comma separated list of components, e.g. client,dockerd.
+// The client is always included.
+func ParseComponents(value string) ([]string, error) {
+	requested := map[string]bool{ClientComponent: true}
+	for _, component := range strings.Split(value, ",") {
+		component = strings.ToLower(strings.TrimSpace(component))
+		if component == "" {
+			continue
+		}
+		if _, ok := componentBinaries[component]; !ok {
+			return nil, errors.Errorf("Unknown component %s, expected one of: %s", component, strings.Join(Components(), ", "))
+		}
+		requested[component] = true
+	}
+
+	var results []string
+	for _, component := range Components() {
+		if requested[component] {
+			results = append(results, component)
+		}
+	}
+	return results, nil
+}
+
+// InstalledComponents lists the components found in a version directory.
+func InstalledComponents(versionDir string, p platform.Platform) []string {
+	var results []string
+	for _, component := range Components() {
+		required := componentBinaries[component][0]
+		for _, binary := range required {
+			if _, err := os.Stat(filepath.Join(versionDir, binary+p.BinaryFileExt())); err == nil {
+				results = append(results, component)
+				break
+			}
+		}
+	}
+	return results
+}
+
+// Build the list of files to save from a release archive, next to the client binary
+func componentFiles(components []string, p platform.Platform, binaryPath string) []downloader.ArchivedFile {
+	var results []downloader.ArchivedFile
+	for _, component := range components {
+		if component == ClientComponent {
+			results = append(results, downloader.ArchivedFile{
+				Path:     filepath.Join("docker", "docker"+p.BinaryFileExt()),
+				DestPath: binaryPath,
+			})
+			continue
+		}
+
+		for _, alternatives := range componentBinaries[component] {
+			for _, binary := range alternatives {
+				results = append(results, downloader.ArchivedFile{
+					Path:     filepath.Join("docker", binary+p.BinaryFileExt()),
+					DestPath: filepath.Join(filepath.Dir(binaryPath), binary+p.BinaryFileExt()),
+					Optional: true,
+				})
+			}
+		}
+	}
+	return results
+}
+
+// Check that every requested component was found in the release archive
+func checkComponents(version Version, components []string, extracted []downloader.ArchivedFile) error {
+	found := make(map[string]bool)
+	for _, file := range extracted {
+		found[strings.TrimSuffix(filepath.Base(file.Path), filepath.Ext(file.Path))] = true
+	}
+
+	var missing []string
+	for _, component := range components {
+		required := componentBinaries[component][0]
+		ok := false
+		for _, binary := range required {
+			ok = ok || found[binary]
+		}
+		if !ok {
+			missing = append(missing, component)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return errors.Errorf("Docker %s was not published with: %s", version, strings.Join(missing, ", "))
+	}
+	return nil
+}
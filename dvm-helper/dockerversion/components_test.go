@@ -0,0 +1,55 @@
+package dockerversion
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/howtowhale/dvm/dvm-helper/internal/downloader"
+	"github.com/howtowhale/dvm/dvm-helper/internal/platform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseComponents(t *testing.T) {
+	components, err := ParseComponents("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"client"}, components, "The client should always be installed")
+
+	components, err = ParseComponents("ctr, runc,containerd,DOCKERD")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"client", "dockerd", "containerd", "runc", "ctr"}, components, "Components should be normalized and ordered")
+
+	_, err = ParseComponents("client,kubelet")
+	assert.Error(t, err, "kubelet is not a component")
+}
+
+func TestCheckComponents(t *testing.T) {
+	p := platform.Platform{OS: "linux", Arch: "x86_64"}
+	binaryPath := filepath.Join("bin", "docker", "17.06.0-ce", "docker")
+
+	files := componentFiles([]string{"client", "runc"}, p, binaryPath)
+	assert.Contains(t, files, downloader.ArchivedFile{Path: filepath.Join("docker", "docker"), DestPath: binaryPath})
+	assert.Contains(t, files, downloader.ArchivedFile{Path: filepath.Join("docker", "docker-runc"), DestPath: filepath.Join("bin", "docker", "17.06.0-ce", "docker-runc"), Optional: true})
+
+	// Older releases prefixed runc with docker-
+	extracted := []downloader.ArchivedFile{{Path: "docker/docker"}, {Path: "docker/docker-runc"}}
+	assert.NoError(t, checkComponents(Parse("17.06.0-ce"), []string{"client", "runc"}, extracted))
+
+	err := checkComponents(Parse("17.06.0-ce"), []string{"client", "runc", "ctr"}, extracted)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "ctr")
+	}
+}
+
+func TestInstalledComponents(t *testing.T) {
+	versionDir, _ := ioutil.TempDir("", "dvmtest")
+	defer os.RemoveAll(versionDir)
+
+	for _, binary := range []string{"docker.exe", "dockerd.exe", "docker-proxy.exe"} {
+		ioutil.WriteFile(filepath.Join(versionDir, binary), nil, 0755)
+	}
+
+	p := platform.Platform{OS: "windows", Arch: "x86_64"}
+	assert.Equal(t, []string{"client", "dockerd"}, InstalledComponents(versionDir, p))
+}
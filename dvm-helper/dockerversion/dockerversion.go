@@ -1,15 +1,17 @@
 package dockerversion
 
 import (
+	"context"
 	"fmt"
 	"net/http"
-	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/semver"
 	"github.com/howtowhale/dvm/dvm-helper/internal/config"
 	"github.com/howtowhale/dvm/dvm-helper/internal/downloader"
+	"github.com/howtowhale/dvm/dvm-helper/internal/mirror"
 	"github.com/pkg/errors"
 )
 
@@ -41,110 +43,280 @@ func Parse(value string) Version {
 	return v
 }
 
-func (version Version) buildDownloadURL(mirror string, forcePrerelease bool) (url string, archived bool, checksumed bool, err error) {
+func (version Version) buildDownloadURL(ctx context.Context, opts config.DvmOptions, forcePrerelease bool) (url string, archived bool, checksumed bool, err error) {
 	var releaseSlug, versionSlug, extSlug string
+	p := opts.Platform
+
+	location, err := mirror.ParseLocation(opts.MirrorURL)
+	if err != nil {
+		return
+	}
 
 	var edgeVersion Version
 	if version.IsEdge() {
-		edgeVersion, err = findLatestEdgeVersion(mirror)
+		edgeVersion, err = findLatestEdgeVersion(ctx, opts)
 		if err != nil {
 			return
 		}
 	}
 
+	releaseSlug = version.releaseChannel(forcePrerelease)
+
 	// Docker Store Download
 	if version.shouldBeInDockerStore() {
 		archived = true
 		checksumed = false
-		extSlug = archiveFileExt
-		if mirror == "" {
-			mirror = "download.docker.com"
-		}
+		extSlug = p.ArchiveFileExt()
 		if version.IsEdge() {
-			releaseSlug = "edge"
 			versionSlug = edgeVersion.String()
-		} else if version.IsPrerelease() || forcePrerelease {
-			releaseSlug = "test"
-			versionSlug = version.String()
 		} else {
-			releaseSlug = "stable"
 			versionSlug = version.String()
 		}
 
-		url = fmt.Sprintf("https://%s/%s/static/%s/%s/docker-%s%s",
-			mirror, mobyOS, releaseSlug, dockerArch, versionSlug, extSlug)
+		url = mirrorTemplate(opts).Expand(location, mirror.Vars{
+			OS:      p.MobyOS(),
+			Channel: releaseSlug,
+			Arch:    p.Arch,
+			Version: versionSlug,
+			Ext:     extSlug,
+		})
 		return
 	} else { // Original Download
 		archived = version.shouldBeArchived()
 		checksumed = true
 		versionSlug = version.String()
 		if archived {
-			extSlug = archiveFileExt
+			extSlug = p.ArchiveFileExt()
 		} else {
-			extSlug = binaryFileExt
+			extSlug = p.BinaryFileExt()
 		}
-		if mirror == "" {
-			mirror = "docker.com"
+
+		// The original releases were published to a subdomain for each channel, e.g. get.docker.com
+		// Mirrors with a path prefix keep them under builds/ instead
+		switch {
+		case opts.MirrorURL == "":
+			url = fmt.Sprintf("https://%s.docker.com/builds/%s/%s/docker-%s%s",
+				releaseSlug, p.DockerOS(), p.Arch, versionSlug, extSlug)
+		case location.HostOnly:
+			url = fmt.Sprintf("https://%s.%s/builds/%s/%s/docker-%s%s",
+				releaseSlug, location.Host, p.DockerOS(), p.Arch, versionSlug, extSlug)
+		default:
+			url = fmt.Sprintf("%sbuilds/%s/%s/docker-%s%s",
+				location.URL(), p.DockerOS(), p.Arch, versionSlug, extSlug)
 		}
-		if version.IsPrerelease() {
-			releaseSlug = "test"
-		} else {
-			releaseSlug = "get"
+		return
+	}
+}
+
+// The layout of a mirror, docker's own servers always use the default layout
+func mirrorTemplate(opts config.DvmOptions) mirror.Template {
+	if opts.MirrorURL == "" || opts.MirrorTemplate == "" {
+		return mirror.DefaultTemplate
+	}
+	return opts.MirrorTemplate
+}
+
+// releaseChannel is the location where a version is published, e.g. stable, test or edge.
+func (version Version) releaseChannel(forcePrerelease bool) string {
+	if version.shouldBeInDockerStore() {
+		if version.IsEdge() {
+			return "edge"
+		}
+		if version.IsPrerelease() || forcePrerelease {
+			return "test"
 		}
+		return "stable"
+	}
 
-		url = fmt.Sprintf("https://%s.%s/builds/%s/%s/docker-%s%s",
-			releaseSlug, mirror, dockerOS, dockerArch, versionSlug, extSlug)
-		return
+	if version.IsPrerelease() {
+		return "test"
+	}
+	return "get"
+}
+
+// DownloadCandidate is a location from which a Docker release may be downloaded.
+type DownloadCandidate struct {
+	URL        string
+	Channel    string
+	Archived   bool
+	Checksumed bool
+}
+
+// DownloadCandidates lists the locations that Download tries for opts.Platform, in order.
+func (version Version) DownloadCandidates(ctx context.Context, opts config.DvmOptions) ([]DownloadCandidate, error) {
+	var results []DownloadCandidate
+	for _, m := range orderMirrors(ctx, opts) {
+		mirrorOpts := opts
+		mirrorOpts.MirrorURL = m
+		mirrorOpts.Mirrors = nil
+
+		candidates, err := version.mirrorCandidates(ctx, mirrorOpts)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, candidates...)
+	}
+	return results, nil
+}
+
+// The locations on opts.MirrorURL where a version may be published
+func (version Version) mirrorCandidates(ctx context.Context, opts config.DvmOptions) ([]DownloadCandidate, error) {
+	var results []DownloadCandidate
+
+	forcePrereleases := []bool{false}
+	if version.hasTestFallback() {
+		forcePrereleases = append(forcePrereleases, true)
+	}
+
+	for _, forcePrerelease := range forcePrereleases {
+		candidate, err := version.buildDownloadCandidate(ctx, opts, forcePrerelease)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, candidate)
 	}
+
+	return results, nil
+}
+
+func (version Version) buildDownloadCandidate(ctx context.Context, opts config.DvmOptions, forcePrerelease bool) (DownloadCandidate, error) {
+	url, archived, checksumed, err := version.buildDownloadURL(ctx, opts, forcePrerelease)
+	if err != nil {
+		return DownloadCandidate{}, errors.Wrapf(err, "Unable to determine the download URL for %s", version)
+	}
+	return DownloadCandidate{
+		URL:        url,
+		Channel:    version.releaseChannel(forcePrerelease),
+		Archived:   archived,
+		Checksumed: checksumed,
+	}, nil
 }
 
-// Download a Docker release.
-// version - the desired version.
-// mirrorURL - optional alternate download location.
+// ReleaseInfo describes a published Docker release.
+type ReleaseInfo struct {
+	DownloadCandidate
+	Size         int64
+	LastModified time.Time
+}
+
+// Inspect finds the first location from which the version can be downloaded and describes it.
+func (version Version) Inspect(ctx context.Context, opts config.DvmOptions) (ReleaseInfo, error) {
+	candidates, err := version.DownloadCandidates(ctx, opts)
+	if err != nil {
+		return ReleaseInfo{}, err
+	}
+
+	for _, candidate := range candidates {
+		opts.Logger.Printf("Checking if %s can be found at %s", version, candidate.URL)
+		request, err := http.NewRequestWithContext(ctx, http.MethodHead, candidate.URL, nil)
+		if err != nil {
+			return ReleaseInfo{}, errors.Wrapf(err, "Invalid download URL %s", candidate.URL)
+		}
+		head, err := opts.HTTPClient.Do(request)
+		if err != nil {
+			// Another mirror may still be reachable
+			if len(candidates) > 1 && ctx.Err() == nil {
+				opts.Logger.Printf("Unable to check %s: %s", candidate.URL, err)
+				continue
+			}
+			return ReleaseInfo{}, errors.Wrapf(err, "Unable to determine if %s is a valid version", version)
+		}
+		head.Body.Close()
+		if head.StatusCode >= 400 {
+			continue
+		}
+
+		info := ReleaseInfo{
+			DownloadCandidate: candidate,
+			Size:              head.ContentLength,
+		}
+		if lastModified, err := http.ParseTime(head.Header.Get("Last-Modified")); err == nil {
+			info.LastModified = lastModified
+		}
+		return info, nil
+	}
+
+	return ReleaseInfo{}, errors.Errorf("Version %s not found - try `dvm ls-remote` to browse available versions", version)
+}
+
+// Download a Docker release, trying each mirror until one has it.
+// ctx - cancels the download, e.g. when the user presses Ctrl-C.
+// opts - the platform, components and optional alternate download locations.
 // binaryPath - full path to where the Docker client binary should be saved.
-func (version Version) Download(opts config.DvmOptions, binaryPath string) error {
-	err := version.download(false, opts, binaryPath)
-	if err != nil && !version.IsPrerelease() && version.shouldBeInDockerStore() {
+// Returns the location from which the release was downloaded.
+func (version Version) Download(ctx context.Context, opts config.DvmOptions, binaryPath string) (DownloadCandidate, error) {
+	var served DownloadCandidate
+	err := eachMirror(ctx, opts, func(opts config.DvmOptions) error {
+		var err error
+		served, err = version.downloadFromMirror(ctx, opts, binaryPath)
+		return err
+	})
+	return served, err
+}
+
+func (version Version) downloadFromMirror(ctx context.Context, opts config.DvmOptions, binaryPath string) (DownloadCandidate, error) {
+	candidate, err := version.download(ctx, false, opts, binaryPath)
+	if err != nil && ctx.Err() == nil && !downloader.IsUnavailable(err) && version.hasTestFallback() {
 		// Docker initially publishes non-rc version versions to the test location
 		// and then later republishes to the stable location
 		// Retry stable versions against test to find "unstable" stable versions. :-)
 		opts.Logger.Printf("Could not find a stable release for %s, checking for a test release\n", version)
-		retryErr := version.download(true, opts, binaryPath)
-		return errors.Wrapf(retryErr, "Attempted to fallback to downloading from the prerelease location after downloading from the stable location failed: %s", err.Error())
+		candidate, retryErr := version.download(ctx, true, opts, binaryPath)
+		return candidate, errors.Wrapf(retryErr, "Attempted to fallback to downloading from the prerelease location after downloading from the stable location failed: %s", err.Error())
 	}
-	return err
+	return candidate, err
 }
 
-func (version Version) download(forcePrerelease bool, opts config.DvmOptions, binaryPath string) error {
-	url, archived, checksumed, err := version.buildDownloadURL(opts.MirrorURL, forcePrerelease)
+func (version Version) download(ctx context.Context, forcePrerelease bool, opts config.DvmOptions, binaryPath string) (DownloadCandidate, error) {
+	candidate, err := version.buildDownloadCandidate(ctx, opts, forcePrerelease)
 	if err != nil {
-		return errors.Wrapf(err, "Unable to determine the download URL for %s", version)
+		return DownloadCandidate{}, err
 	}
+	return candidate, version.downloadCandidate(ctx, opts, candidate, binaryPath)
+}
+
+// Download a version from a single location
+func (version Version) downloadCandidate(ctx context.Context, opts config.DvmOptions, candidate DownloadCandidate, binaryPath string) error {
+	url, archived, checksumed := candidate.URL, candidate.Archived, candidate.Checksumed
+
+	d := downloader.New(opts)
 
 	opts.Logger.Printf("Checking if %s can be found at %s", version, url)
-	head, err := http.Head(url)
+	exists, err := d.Exists(ctx, url)
 	if err != nil {
 		return errors.Wrapf(err, "Unable to determine if %s is a valid version", version)
 	}
-	if head.StatusCode >= 400 {
-		return errors.Errorf("Version %s not found (%v) - try `dvm ls-remote` to browse available versions", version, head.StatusCode)
+	if !exists {
+		return errors.Errorf("Version %s not found - try `dvm ls-remote` to browse available versions", version)
 	}
 
-	d := downloader.New(opts)
-	binaryName := filepath.Base(binaryPath)
+	components := opts.Components
+	if len(components) == 0 {
+		components = []string{ClientComponent}
+	}
 
 	if archived {
-		archivedFile := filepath.Join("docker", binaryName)
+		files := componentFiles(components, opts.Platform, binaryPath)
+		var extracted []downloader.ArchivedFile
 		if checksumed {
-			return d.DownloadArchivedFileWithChecksum(url, archivedFile, binaryPath)
+			extracted, err = d.DownloadArchivedFilesWithChecksum(ctx, url, files)
+		} else {
+			extracted, err = d.DownloadArchivedFiles(ctx, url, files)
+		}
+		if err != nil {
+			return err
 		}
-		return d.DownloadArchivedFile(url, archivedFile, binaryPath)
+		return checkComponents(version, components, extracted)
+	}
+
+	if len(components) > 1 {
+		return errors.Errorf("Docker %s was only published as a client, it cannot be installed with: %s", version, strings.Join(components[1:], ", "))
 	}
 
 	if checksumed {
-		return d.DownloadFileWithChecksum(url, binaryPath)
+		return d.DownloadFileWithChecksum(ctx, url, binaryPath)
 	}
-	return d.DownloadFile(url, binaryPath)
+	return d.DownloadFile(ctx, url, binaryPath)
 }
 
 func (version Version) shouldBeInDockerStore() bool {
@@ -157,6 +329,12 @@ func (version Version) shouldBeInDockerStore() bool {
 	return version.semver != nil && !version.semver.LessThan(dockerStoreCutoff)
 }
 
+// Stable releases may only have been published to the test channel so far.
+// Edge is always downloaded from its own channel, so it has no fallback.
+func (version Version) hasTestFallback() bool {
+	return !version.IsPrerelease() && !version.IsEdge() && version.shouldBeInDockerStore()
+}
+
 func (version Version) shouldBeArchived() bool {
 	archivedReleaseCutoff, _ := semver.NewVersion("1.11.0-rc1")
 	return version.semver != nil && !version.semver.LessThan(archivedReleaseCutoff)
@@ -1,6 +0,0 @@
-// +build !windows
-
-package dockerversion
-
-const archiveFileExt string = ".tgz"
-const binaryFileExt string = ""
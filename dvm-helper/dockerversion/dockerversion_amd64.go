@@ -1,3 +0,0 @@
-package dockerversion
-
-const dockerArch string = "x86_64"
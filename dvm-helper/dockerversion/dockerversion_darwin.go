@@ -1,4 +0,0 @@
-package dockerversion
-
-const dockerOS string = "Darwin"
-const mobyOS string = "mac"
@@ -1,4 +0,0 @@
-package dockerversion
-
-const dockerOS string = "Linux"
-const mobyOS string = "linux"
@@ -1,6 +1,7 @@
 package dockerversion
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -8,8 +9,10 @@ import (
 	"testing"
 
 	"github.com/howtowhale/dvm/dvm-helper/internal/config"
+	"github.com/howtowhale/dvm/dvm-helper/internal/mirror"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestStripLeadingV(t *testing.T) {
@@ -118,6 +121,10 @@ func TestSetAsSystem(t *testing.T) {
 }
 
 func TestVersion_BuildDownloadURL(t *testing.T) {
+	opts := config.NewDvmOptions()
+	p := opts.Platform
+	dockerOS, mobyOS, dockerArch, archiveFileExt := p.DockerOS(), p.MobyOS(), p.Arch, p.ArchiveFileExt()
+
 	testcases := map[Version]struct {
 		wantURL      string
 		wantArchived bool
@@ -181,7 +188,7 @@ func TestVersion_BuildDownloadURL(t *testing.T) {
 
 	for version, testcase := range testcases {
 		t.Run(version.String(), func(t *testing.T) {
-			gotURL, gotArchived, gotChecksumed, err := version.buildDownloadURL("", false)
+			gotURL, gotArchived, gotChecksumed, err := version.buildDownloadURL(context.Background(), opts, false)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -208,6 +215,42 @@ func TestVersion_BuildDownloadURL(t *testing.T) {
 	}
 }
 
+func TestVersion_BuildDownloadURL_Mirror(t *testing.T) {
+	opts := config.NewDvmOptions()
+	p := opts.Platform
+	dockerOS, mobyOS, dockerArch, archiveFileExt := p.DockerOS(), p.MobyOS(), p.Arch, p.ArchiveFileExt()
+
+	testcases := []struct {
+		mirror   string
+		template mirror.Template
+		version  string
+		wantURL  string
+	}{
+		{"http://artifacts.corp/docker-remote/", "", "20.10.24",
+			fmt.Sprintf("http://artifacts.corp/docker-remote/%s/static/stable/%s/docker-20.10.24%s", mobyOS, dockerArch, archiveFileExt)},
+		{"http://artifacts.corp/docker-remote/", "", "17.03.0-ce",
+			fmt.Sprintf("http://artifacts.corp/docker-remote/builds/%s/%s/docker-17.03.0-ce%s", dockerOS, dockerArch, archiveFileExt)},
+		{"example.com", "", "20.10.24",
+			fmt.Sprintf("https://example.com/%s/static/stable/%s/docker-20.10.24%s", mobyOS, dockerArch, archiveFileExt)},
+		{"example.com", "", "17.03.0-ce",
+			fmt.Sprintf("https://get.example.com/builds/%s/%s/docker-17.03.0-ce%s", dockerOS, dockerArch, archiveFileExt)},
+		{"https://artifacts.corp/docker/", "{scheme}://{host}/{prefix}/{channel}/{os}-{arch}/docker-{version}{ext}", "20.10.24",
+			fmt.Sprintf("https://artifacts.corp/docker/stable/%s-%s/docker-20.10.24%s", mobyOS, dockerArch, archiveFileExt)},
+		{"", "{scheme}://{host}/{prefix}/{channel}/{os}-{arch}/docker-{version}{ext}", "20.10.24",
+			fmt.Sprintf("https://download.docker.com/%s/static/stable/%s/docker-20.10.24%s", mobyOS, dockerArch, archiveFileExt)},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.mirror+" "+tc.version, func(t *testing.T) {
+			opts.MirrorURL = tc.mirror
+			opts.MirrorTemplate = tc.template
+			gotURL, _, _, err := Parse(tc.version).buildDownloadURL(context.Background(), opts, false)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantURL, gotURL)
+		})
+	}
+}
+
 func TestVersion_DownloadEdgeRelease(t *testing.T) {
 	version := Parse("edge")
 	tempDir, _ := ioutil.TempDir("", "dvmtest")
@@ -215,8 +258,43 @@ func TestVersion_DownloadEdgeRelease(t *testing.T) {
 	opts.DvmDir = filepath.Join(tempDir, ".dvm")
 	destPath := filepath.Join(opts.DvmDir, "docker")
 
-	err := version.Download(opts, destPath)
+	_, err := version.Download(context.Background(), opts, destPath)
+	if err != nil {
+		t.Fatalf("%#v", err)
+	}
+}
+
+func TestVersion_DownloadCandidates(t *testing.T) {
+	opts := config.NewDvmOptions()
+	p := opts.Platform
+	mobyOS, dockerArch, archiveFileExt := p.MobyOS(), p.Arch, p.ArchiveFileExt()
+
+	candidates, err := Parse("17.09.0-ce").DownloadCandidates(context.Background(), opts)
 	if err != nil {
 		t.Fatalf("%#v", err)
 	}
+
+	if assert.Len(t, candidates, 2, "A stable release should fallback to the test location") {
+		assert.Equal(t, "stable", candidates[0].Channel)
+		assert.Equal(t, fmt.Sprintf("https://download.docker.com/%s/static/stable/%s/docker-17.09.0-ce%s", mobyOS, dockerArch, archiveFileExt), candidates[0].URL)
+		assert.Equal(t, "test", candidates[1].Channel)
+		assert.Equal(t, fmt.Sprintf("https://download.docker.com/%s/static/test/%s/docker-17.09.0-ce%s", mobyOS, dockerArch, archiveFileExt), candidates[1].URL)
+	}
+
+	candidates, err = Parse("17.10.0-ce-rc1").DownloadCandidates(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("%#v", err)
+	}
+	if assert.Len(t, candidates, 1, "A prerelease should only be downloaded from the test location") {
+		assert.Equal(t, "test", candidates[0].Channel)
+	}
+
+	candidates, err = Parse("1.12.1").DownloadCandidates(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("%#v", err)
+	}
+	if assert.Len(t, candidates, 1) {
+		assert.Equal(t, "get", candidates[0].Channel)
+		assert.True(t, candidates[0].Checksumed, "Releases from the original location should be checksumed")
+	}
 }
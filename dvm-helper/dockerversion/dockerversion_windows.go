@@ -1,6 +0,0 @@
-package dockerversion
-
-const dockerOS string = "Windows"
-const mobyOS string = "win"
-const archiveFileExt string = ".zip"
-const binaryFileExt string = ".exe"
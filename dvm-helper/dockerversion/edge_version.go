@@ -1,7 +1,13 @@
 package dockerversion
 
-func findLatestEdgeVersion(mirrorURL string) (Version, error) {
-	results, err := ListVersions(mirrorURL, Edge)
+import (
+	"context"
+
+	"github.com/howtowhale/dvm/dvm-helper/internal/config"
+)
+
+func findLatestEdgeVersion(ctx context.Context, opts config.DvmOptions) (Version, error) {
+	results, err := ListVersions(ctx, opts, Edge)
 	if err != nil {
 		return Version{}, err
 	}
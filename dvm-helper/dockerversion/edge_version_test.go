@@ -1,12 +1,15 @@
 package dockerversion
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/howtowhale/dvm/dvm-helper/internal/config"
 	"github.com/howtowhale/dvm/dvm-helper/internal/test"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestVersion_findLatestEdgeVersion(t *testing.T) {
@@ -15,7 +18,9 @@ func TestVersion_findLatestEdgeVersion(t *testing.T) {
 		fmt.Fprintln(w, test.LoadTestData("edge_releases.html"))
 	}))
 
-	v, err := findLatestEdgeVersion(releaseListing.URL)
+	opts := config.NewDvmOptions()
+	opts.MirrorURL = releaseListing.URL
+	v, err := findLatestEdgeVersion(context.Background(), opts)
 	if err != nil {
 		t.Fatalf("%#v", err)
 	}
@@ -26,3 +31,23 @@ func TestVersion_findLatestEdgeVersion(t *testing.T) {
 		t.Fatalf("Expected '%s', got '%s'", wantV, gotV)
 	}
 }
+
+func TestVersion_DownloadCandidates_Edge(t *testing.T) {
+	releaseListing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintln(w, test.LoadTestData("edge_releases.html"))
+	}))
+	defer releaseListing.Close()
+
+	opts := config.NewDvmOptions()
+	opts.MirrorURL = releaseListing.URL
+	candidates, err := Parse("edge").DownloadCandidates(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("%#v", err)
+	}
+
+	if assert.Len(t, candidates, 1, "Edge should not fallback to the same URL") {
+		assert.Equal(t, "edge", candidates[0].Channel)
+		assert.Contains(t, candidates[0].URL, "/edge/")
+	}
+}
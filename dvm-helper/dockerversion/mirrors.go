@@ -0,0 +1,63 @@
+package dockerversion
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/howtowhale/dvm/dvm-helper/internal/config"
+	"github.com/howtowhale/dvm/dvm-helper/internal/downloader"
+	"github.com/howtowhale/dvm/dvm-helper/internal/mirror"
+	"github.com/pkg/errors"
+)
+
+// The mirrors to try, in order. Without a mirror list only opts.MirrorURL is used.
+func orderMirrors(ctx context.Context, opts config.DvmOptions) []string {
+	if opts.Mirrors == nil {
+		return []string{opts.MirrorURL}
+	}
+	return opts.Mirrors.Order(ctx)
+}
+
+// Call fn with each mirror in turn until it succeeds.
+// Mirrors that can't be reached or return server errors are remembered, so that they are tried last next time.
+func eachMirror(ctx context.Context, opts config.DvmOptions, fn func(opts config.DvmOptions) error) error {
+	mirrors := orderMirrors(ctx, opts)
+
+	var failures []string
+	var err error
+	for i, m := range mirrors {
+		mirrorOpts := opts
+		mirrorOpts.MirrorURL = m
+		mirrorOpts.Mirrors = nil
+
+		// Move on to the next mirror instead of waiting to retry, only the last mirror is retried
+		if i < len(mirrors)-1 {
+			mirrorOpts.Retries = 0
+		}
+
+		err = fn(mirrorOpts)
+		if err == nil {
+			if opts.Mirrors != nil {
+				opts.Mirrors.Succeeded(m)
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+
+		if opts.Mirrors != nil && downloader.IsUnavailable(err) {
+			opts.Mirrors.Failed(m)
+		}
+		if len(mirrors) > 1 {
+			opts.Logger.Printf("Unable to use %s: %s\n", mirror.Name(m), err)
+		}
+		failures = append(failures, fmt.Sprintf("%s: %s", mirror.Name(m), err))
+	}
+
+	if len(mirrors) == 1 {
+		return err
+	}
+	return errors.Errorf("Unable to use any of the mirrors:\n%s", strings.Join(failures, "\n"))
+}
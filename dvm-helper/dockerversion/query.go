@@ -2,12 +2,12 @@ package dockerversion
 
 import (
 	"bytes"
-	"fmt"
+	"context"
 	"net/http"
-	"net/url"
-
-	"regexp"
 
+	"github.com/howtowhale/dvm/dvm-helper/internal/config"
+	"github.com/howtowhale/dvm/dvm-helper/internal/downloader"
+	"github.com/howtowhale/dvm/dvm-helper/internal/mirror"
 	"github.com/pkg/errors"
 )
 
@@ -19,32 +19,53 @@ const (
 	Stable ReleaseType = "stable"
 )
 
-var hrefRegex = regexp.MustCompile(fmt.Sprintf(`href="docker-(.*)\%s"`, archiveFileExt))
+// ListVersions finds the versions published for opts.Platform, trying each mirror until one responds.
+func ListVersions(ctx context.Context, opts config.DvmOptions, releaseType ReleaseType) ([]Version, error) {
+	var results []Version
+	err := eachMirror(ctx, opts, func(opts config.DvmOptions) error {
+		var err error
+		results, err = listVersions(ctx, opts, releaseType)
+		if err == nil {
+			opts.Logger.Printf("Listed %s releases from %s\n", releaseType, mirror.Name(opts.MirrorURL))
+		}
+		return err
+	})
+	return results, err
+}
 
-func ListVersions(mirrorURL string, releaseType ReleaseType) ([]Version, error) {
-	if mirrorURL == "" {
-		mirrorURL = "https://download.docker.com"
+// Find the versions published for opts.Platform on opts.MirrorURL
+func listVersions(ctx context.Context, opts config.DvmOptions, releaseType ReleaseType) ([]Version, error) {
+	p := opts.Platform
+	location, err := mirror.ParseLocation(opts.MirrorURL)
+	if err != nil {
+		return nil, err
 	}
 
-	mirror, err := url.Parse(mirrorURL)
+	template := mirrorTemplate(opts)
+	vars := mirror.Vars{OS: p.MobyOS(), Channel: string(releaseType), Arch: p.Arch, Ext: p.ArchiveFileExt()}
+	indexURL := template.Dir(location, vars)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, indexURL, nil)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Unable to parse the mirror URL: %s", mirrorURL)
+		return nil, errors.Wrapf(err, "Invalid release listing URL %s", indexURL)
 	}
-
-	indexURL := fmt.Sprintf("%s://%s/%s/static/%s/%s", mirror.Scheme, mirror.Host, mobyOS, releaseType, dockerArch)
-	response, err := http.Get(indexURL)
+	response, err := opts.HTTPClient.Do(request)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Unable to list %s releases at %s", releaseType, indexURL)
 	}
 	defer response.Body.Close()
 
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.Wrapf(downloader.HTTPError{URL: indexURL, StatusCode: response.StatusCode}, "Unable to list %s releases", releaseType)
+	}
+
 	b := bytes.Buffer{}
 	_, err = b.ReadFrom(response.Body)
 	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read the listing of %s releases at %s", releaseType, indexURL)
 	}
-	errors.Wrapf(err, "Unable to read the listing of %s releases at %s", releaseType, indexURL)
 
-	matches := hrefRegex.FindAllStringSubmatch(b.String(), -1)
+	matches := template.FileRegex(location, vars).FindAllStringSubmatch(b.String(), -1)
 	var results []Version
 	for _, match := range matches {
 		version := Parse(match[1])
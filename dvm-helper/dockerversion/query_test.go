@@ -0,0 +1,140 @@
+package dockerversion
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/howtowhale/dvm/dvm-helper/internal/config"
+	"github.com/howtowhale/dvm/dvm-helper/internal/httpclient"
+	"github.com/howtowhale/dvm/dvm-helper/internal/mirror"
+	"github.com/howtowhale/dvm/dvm-helper/internal/platform"
+	"github.com/howtowhale/dvm/dvm-helper/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListVersions_Platform(t *testing.T) {
+	var gotPath string
+	releaseListing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintln(w, test.LoadTestData("win_stable_releases.html"))
+	}))
+	defer releaseListing.Close()
+
+	p, err := platform.Parse("windows", "x86_64")
+	if err != nil {
+		t.Fatalf("%#v", err)
+	}
+
+	opts := config.NewDvmOptions()
+	opts.MirrorURL = releaseListing.URL
+	opts.Platform = p
+	versions, err := ListVersions(context.Background(), opts, Stable)
+	if err != nil {
+		t.Fatalf("%#v", err)
+	}
+
+	assert.Equal(t, "/win/static/stable/x86_64", gotPath)
+	if assert.Len(t, versions, 3) {
+		assert.Equal(t, "17.09.0-ce", versions[0].String())
+		assert.Equal(t, "20.10.24", versions[2].String())
+	}
+}
+
+func TestListVersions_AuthenticatedMirror(t *testing.T) {
+	releaseListing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer abc123" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintln(w, test.LoadTestData("win_stable_releases.html"))
+	}))
+	defer releaseListing.Close()
+
+	p, err := platform.Parse("windows", "x86_64")
+	require.NoError(t, err)
+
+	opts := config.NewDvmOptions()
+	opts.MirrorURL = releaseListing.URL
+	opts.Platform = p
+
+	_, err = ListVersions(context.Background(), opts, Stable)
+	assert.Error(t, err, "The listing should be rejected without credentials")
+
+	opts.HTTPClient, err = httpclient.New(httpclient.Options{
+		MirrorAuth: httpclient.MirrorAuth{Hosts: []string{httpclient.MirrorHost(releaseListing.URL)}, Token: "abc123"},
+	})
+	require.NoError(t, err)
+
+	versions, err := ListVersions(context.Background(), opts, Stable)
+	require.NoError(t, err)
+	assert.Len(t, versions, 3)
+}
+
+func TestListVersions_MirrorFallback(t *testing.T) {
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	incomplete := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer incomplete.Close()
+	releaseListing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintln(w, test.LoadTestData("win_stable_releases.html"))
+	}))
+	defer releaseListing.Close()
+
+	p, err := platform.Parse("windows", "x86_64")
+	require.NoError(t, err)
+
+	opts := config.NewDvmOptions()
+	opts.Platform = p
+	mirrors := []string{unavailable.URL, incomplete.URL, releaseListing.URL}
+	opts.Mirrors = mirror.New(mirrors, mirror.InOrder, "", opts.HTTPClient, opts.Logger)
+
+	versions, err := ListVersions(context.Background(), opts, Stable)
+	require.NoError(t, err)
+	assert.Len(t, versions, 3)
+
+	assert.Equal(t, []string{incomplete.URL, releaseListing.URL, unavailable.URL}, opts.Mirrors.Order(context.Background()),
+		"Only the unavailable mirror should be tried last")
+
+	releaseListing.Close()
+	_, err = ListVersions(context.Background(), opts, Stable)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Unable to use any of the mirrors")
+}
+
+func TestListVersions_MirrorPrefix(t *testing.T) {
+	var gotPath string
+	releaseListing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintln(w, test.LoadTestData("win_stable_releases.html"))
+	}))
+	defer releaseListing.Close()
+
+	p, err := platform.Parse("windows", "x86_64")
+	require.NoError(t, err)
+
+	opts := config.NewDvmOptions()
+	opts.MirrorURL = releaseListing.URL + "/docker-remote/"
+	opts.Platform = p
+	versions, err := ListVersions(context.Background(), opts, Stable)
+	require.NoError(t, err)
+	assert.Equal(t, "/docker-remote/win/static/stable/x86_64", gotPath)
+	assert.Len(t, versions, 3)
+
+	opts.MirrorTemplate = "{scheme}://{host}/{prefix}/{channel}/{os}-{arch}/docker-{version}{ext}"
+	versions, err = ListVersions(context.Background(), opts, Stable)
+	require.NoError(t, err)
+	assert.Equal(t, "/docker-remote/stable/win-x86_64", gotPath)
+	assert.Len(t, versions, 3)
+}
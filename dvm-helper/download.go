@@ -0,0 +1,148 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
+)
+
+// Save a Docker client for opts.Platform into a directory without touching DVM_DIR or the current shell,
+// e.g. to put a client in /usr/local/bin in a Dockerfile, or to assemble clients for other platforms
+// dir - directory where the client is saved
+// name - file name of the client, defaults to docker with the platform's executable extension
+func downloadToDir(version dockerversion.Version, dir string, name string) {
+	if version.IsSystem() || (version.IsEmpty() && !version.IsEdge()) {
+		die("%s is not a valid version to download.", nil, retCodeInvalidArgument, version)
+	}
+	validateDownloadPlatform()
+
+	dir = filepath.Clean(dir)
+	destPath := getDownloadPath(dir, name)
+	name = filepath.Base(destPath)
+
+	// Stage the download next to its destination so that it can be renamed into place.
+	// A new directory is staged beside it, so that the whole directory is moved in at once.
+	_, err := os.Stat(dir)
+	newDir := os.IsNotExist(err)
+	stagingParent := dir
+	if newDir {
+		stagingParent = filepath.Dir(dir)
+	}
+	if err = os.MkdirAll(stagingParent, 0755); err != nil {
+		die("Unable to create %s.", err, retCodeRuntimeError, stagingParent)
+	}
+
+	stagingDir, err := ioutil.TempDir(stagingParent, ".dvm-")
+	if err != nil {
+		die("Unable to create a temporary directory in %s.", err, retCodeRuntimeError, stagingParent)
+	}
+	defer os.RemoveAll(stagingDir)
+	onExit(func() { os.RemoveAll(stagingDir) })
+
+	// The downloader keeps its temporary files in the staging directory, apart from the staged files
+	stagedDir := filepath.Join(stagingDir, "files")
+	if err = os.MkdirAll(stagedDir, 0755); err != nil {
+		die("Unable to create %s.", err, retCodeRuntimeError, stagedDir)
+	}
+
+	writeInfo("Downloading %s for %s to %s...", version, opts.Platform, destPath)
+
+	downloadOpts := opts
+	downloadOpts.DvmDir = stagingDir
+	stagingPath := filepath.Join(stagedDir, name)
+	served, err := version.Download(appCtx, downloadOpts, stagingPath)
+	if err != nil {
+		os.RemoveAll(stagingDir)
+		die("", err, retCodeRuntimeError)
+	}
+	reportMirror(served)
+
+	err = validateDockerBinary(stagingPath, opts.Platform.OS, opts.Platform.GOARCH())
+	if err != nil {
+		os.RemoveAll(stagingDir)
+		die("Docker %s failed validation and was not saved.", err, retCodeRuntimeError, version)
+	}
+
+	if err = os.Chmod(stagingPath, 0755); err != nil {
+		os.RemoveAll(stagingDir)
+		die("Unable to make %s executable.", err, retCodeRuntimeError, stagingPath)
+	}
+
+	stagedFiles, err := ioutil.ReadDir(stagedDir)
+	if err != nil {
+		os.RemoveAll(stagingDir)
+		die("Unable to read %s.", err, retCodeRuntimeError, stagedDir)
+	}
+
+	if newDir {
+		if err = os.Rename(stagedDir, dir); err != nil {
+			os.RemoveAll(stagingDir)
+			die("Unable to move %s to %s.", err, retCodeRuntimeError, stagedDir, dir)
+		}
+	} else {
+		commitStagedFiles(stagedDir, stagedFiles, dir, filepath.Join(stagingDir, "replaced"))
+	}
+
+	writeInfo("Saved Docker %s to %s", version, destPath)
+	// Report the other components, e.g. dockerd, which were saved next to the client
+	for _, stagedFile := range stagedFiles {
+		if stagedFile.Name() != name {
+			writeInfo("Saved %s to %s", stagedFile.Name(), filepath.Join(dir, stagedFile.Name()))
+		}
+	}
+}
+
+// Move staged files into an existing directory as a unit. Replaced files are kept in backupDir
+// until every file has been moved, so that a failure puts the directory back the way it was.
+func commitStagedFiles(stagedDir string, stagedFiles []os.FileInfo, dir string, backupDir string) {
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		die("Unable to create %s.", err, retCodeRuntimeError, backupDir)
+	}
+
+	var committed []string
+	replaced := make(map[string]bool)
+	rollback := func() {
+		for i := len(committed) - 1; i >= 0; i-- {
+			destPath := filepath.Join(dir, committed[i])
+			os.Remove(destPath)
+			if replaced[committed[i]] {
+				os.Rename(filepath.Join(backupDir, committed[i]), destPath)
+			}
+		}
+	}
+
+	for _, stagedFile := range stagedFiles {
+		fileName := stagedFile.Name()
+		destPath := filepath.Join(dir, fileName)
+		if _, err := os.Lstat(destPath); err == nil {
+			if err = os.Rename(destPath, filepath.Join(backupDir, fileName)); err != nil {
+				rollback()
+				die("Unable to replace %s.", err, retCodeRuntimeError, destPath)
+			}
+			replaced[fileName] = true
+		}
+
+		if err := os.Rename(filepath.Join(stagedDir, fileName), destPath); err != nil {
+			if replaced[fileName] {
+				os.Rename(filepath.Join(backupDir, fileName), destPath)
+			}
+			rollback()
+			die("Unable to move %s to %s.", err, retCodeRuntimeError, fileName, dir)
+		}
+		committed = append(committed, fileName)
+	}
+}
+
+// Get the path where downloadToDir saves the client, defaulting the file name
+// to docker with the platform's executable extension
+func getDownloadPath(dir string, name string) string {
+	if name == "" {
+		name = "docker" + opts.Platform.BinaryFileExt()
+	}
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		die("The client name %s must be a file name, not a path.", nil, retCodeInvalidArgument, name)
+	}
+	return filepath.Join(dir, name)
+}
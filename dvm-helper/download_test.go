@@ -0,0 +1,173 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
+	"github.com/howtowhale/dvm/dvm-helper/internal/platform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Build the smallest PE header that passes validation as a windows/amd64 executable
+func createStubWindowsBinary() []byte {
+	const peOffset = 0x80
+	stub := make([]byte, peOffset+24)
+	copy(stub, "MZ")
+	binary.LittleEndian.PutUint32(stub[0x3c:], peOffset)
+	copy(stub[peOffset:], "PE\x00\x00")
+	binary.LittleEndian.PutUint16(stub[peOffset+4:], 0x8664)  // IMAGE_FILE_MACHINE_AMD64
+	binary.LittleEndian.PutUint16(stub[peOffset+22:], 0x0002) // IMAGE_FILE_EXECUTABLE_IMAGE
+	return stub
+}
+
+// createMockMirror serves a Windows release archive containing the specified files for every version
+func createMockMirror(t *testing.T, files map[string][]byte) *httptest.Server {
+	archive := &bytes.Buffer{}
+	zw := zip.NewWriter(archive)
+	for name, contents := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write(contents)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, ".zip") {
+			w.WriteHeader(404)
+			return
+		}
+		http.ServeContent(w, r, filepath.Base(r.URL.Path), time.Now(), bytes.NewReader(archive.Bytes()))
+	}))
+	t.Cleanup(mirror.Close)
+	return mirror
+}
+
+// Download Windows clients from a mock mirror, so that the clients are validated without being run
+func setupDownloadTest(t *testing.T, files map[string][]byte) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The stub client would be run by the smoke test")
+	}
+
+	setupTestDvmDir(t)
+	mirror := createMockMirror(t, files)
+
+	oldPlatform, oldMirrorURL, oldComponents := opts.Platform, opts.MirrorURL, opts.Components
+	t.Cleanup(func() { opts.Platform, opts.MirrorURL, opts.Components = oldPlatform, oldMirrorURL, oldComponents })
+
+	p, err := platform.Parse("windows", "x86_64")
+	require.NoError(t, err)
+	opts.Platform = p
+	opts.MirrorURL = mirror.URL
+	opts.Components = nil
+}
+
+func TestDownloadToDir_BinDir(t *testing.T) {
+	setupDownloadTest(t, map[string][]byte{"docker/docker.exe": createStubWindowsBinary()})
+	binDir := filepath.Join(opts.DvmDir, "bin-dir")
+
+	downloadToDir(dockerversion.Parse("20.10.24"), binDir, "")
+
+	contents, err := ioutil.ReadFile(filepath.Join(binDir, "docker.exe"))
+	require.NoError(t, err)
+	assert.Equal(t, createStubWindowsBinary(), contents)
+
+	leftovers, _ := ioutil.ReadDir(binDir)
+	assert.Len(t, leftovers, 1, "Only the client should be saved")
+	leftovers, _ = ioutil.ReadDir(opts.DvmDir)
+	assert.Len(t, leftovers, 1, "The staging directory should be removed")
+}
+
+func TestDownloadToDir_Components(t *testing.T) {
+	setupDownloadTest(t, map[string][]byte{
+		"docker/docker.exe":  createStubWindowsBinary(),
+		"docker/dockerd.exe": []byte("dockerd"),
+	})
+	opts.Components = []string{"client", "dockerd"}
+
+	// A new directory is moved into place with every component
+	newDir := filepath.Join(opts.DvmDir, "new", "bin") + string(os.PathSeparator)
+	downloadToDir(dockerversion.Parse("20.10.24"), newDir, "")
+
+	for _, fileName := range []string{"docker.exe", "dockerd.exe"} {
+		_, err := os.Stat(filepath.Join(newDir, fileName))
+		assert.NoError(t, err, "%s should be saved to the new directory", fileName)
+	}
+	leftovers, _ := ioutil.ReadDir(filepath.Join(opts.DvmDir, "new"))
+	assert.Len(t, leftovers, 1, "The staging directory should be removed")
+
+	// Components replace those already in an existing directory
+	existingDir := filepath.Join(opts.DvmDir, "existing")
+	require.NoError(t, os.MkdirAll(existingDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(existingDir, "dockerd.exe"), []byte("old"), 0755))
+	downloadToDir(dockerversion.Parse("20.10.24"), existingDir, "")
+
+	contents, err := ioutil.ReadFile(filepath.Join(existingDir, "dockerd.exe"))
+	require.NoError(t, err)
+	assert.Equal(t, "dockerd", string(contents), "The existing component should be replaced")
+	leftovers, _ = ioutil.ReadDir(existingDir)
+	assert.Len(t, leftovers, 2, "The staging directory should be removed")
+}
+
+func TestDownloadToDir_Overwrites(t *testing.T) {
+	setupDownloadTest(t, map[string][]byte{"docker/docker.exe": createStubWindowsBinary()})
+	binDir := filepath.Join(opts.DvmDir, "bin-dir")
+	require.NoError(t, os.MkdirAll(binDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(binDir, "docker.exe"), []byte("old"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(binDir, "other.exe"), []byte("other"), 0755))
+
+	downloadToDir(dockerversion.Parse("20.10.24"), binDir, "")
+
+	contents, err := ioutil.ReadFile(filepath.Join(binDir, "docker.exe"))
+	require.NoError(t, err)
+	assert.Equal(t, createStubWindowsBinary(), contents, "The existing client should be replaced")
+
+	contents, err = ioutil.ReadFile(filepath.Join(binDir, "other.exe"))
+	require.NoError(t, err)
+	assert.Equal(t, "other", string(contents), "Other files in the directory should be kept")
+}
+
+func TestDownloadToDir_Name(t *testing.T) {
+	setupDownloadTest(t, map[string][]byte{"docker/docker.exe": createStubWindowsBinary()})
+	binDir := filepath.Join(opts.DvmDir, "bin-dir")
+
+	downloadToDir(dockerversion.Parse("20.10.24"), binDir, "docker-20.10.exe")
+
+	_, err := os.Stat(filepath.Join(binDir, "docker-20.10.exe"))
+	assert.NoError(t, err, "The client should be saved with the specified name")
+	_, err = os.Stat(filepath.Join(binDir, "docker.exe"))
+	assert.True(t, os.IsNotExist(err), "The client should not be saved with the default name")
+}
+
+func TestInstallDryRun_BinDir(t *testing.T) {
+	setupDownloadTest(t, nil)
+	binDir := filepath.Join(opts.DvmDir, "bin-dir")
+	destPath := getDownloadPath(binDir, "docker-20.10.exe")
+	require.NoError(t, os.MkdirAll(binDir, 0755))
+	require.NoError(t, ioutil.WriteFile(destPath, []byte("old"), 0755))
+
+	outputCapture := &bytes.Buffer{}
+	oldOutput := color.Output
+	color.Output = outputCapture
+	defer func() { color.Output = oldOutput }()
+
+	installDryRun(dockerversion.Parse("20.10.24"), destPath)
+
+	output := outputCapture.String()
+	assert.Contains(t, output, "Destination:\t"+destPath)
+	assert.NotContains(t, output, getVersionDir(dockerversion.Parse("20.10.24")), "The version directory is not used by --bin-dir")
+	assert.Contains(t, output, "would be replaced")
+}
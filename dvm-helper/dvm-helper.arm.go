@@ -0,0 +1,7 @@
+//go:build arm
+// +build arm
+
+package main
+
+// Matches uname -m, which install.sh uses to download dvm-helper
+const dvmArch string = "armv7l"
@@ -5,20 +5,23 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	neturl "net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/semver"
 	"github.com/codegangsta/cli"
-	dockerclient "github.com/docker/docker/client"
 	"github.com/fatih/color"
 	"github.com/google/go-github/github"
 	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
 	"github.com/howtowhale/dvm/dvm-helper/internal/config"
+	"github.com/howtowhale/dvm/dvm-helper/internal/httpclient"
+	"github.com/howtowhale/dvm/dvm-helper/internal/mirror"
+	"github.com/howtowhale/dvm/dvm-helper/internal/platform"
 	"github.com/howtowhale/dvm/dvm-helper/url"
 	"github.com/pkg/errors"
 	"github.com/ryanuber/go-glob"
@@ -41,10 +44,12 @@ const (
 	retCodeInvalidArgument  = 127
 	retCodeInvalidOperation = 3
 	retCodeRuntimeError     = 1
+	retCodeCancelled        = 130
 	versionEnvVar           = "DOCKER_VERSION"
 )
 
 func main() {
+	handleSignals()
 	app := makeCliApp()
 	app.Run(os.Args)
 }
@@ -61,29 +66,94 @@ func makeCliApp() *cli.App {
 		cli.StringFlag{Name: "shell", EnvVar: "SHELL", Usage: "Specify the shell format in which environment variables should be output, e.g. powershell, cmd or sh/bash. Defaults to sh/bash."},
 		cli.BoolFlag{Name: "debug", Usage: "Print additional debug information."},
 		cli.BoolFlag{Name: "silent", EnvVar: "DVM_SILENT", Usage: "Suppress output. Errors will still be displayed."},
+		cli.DurationFlag{Name: "connect-timeout", EnvVar: "DVM_CONNECT_TIMEOUT", Value: config.DefaultConnectTimeout, Usage: "How long to wait when connecting to a download server. Use 0 to wait indefinitely."},
+		cli.DurationFlag{Name: "read-timeout", EnvVar: "DVM_READ_TIMEOUT", Value: config.DefaultReadTimeout, Usage: "How long a download may stall before it is abandoned. Use 0 to wait indefinitely."},
+		cli.IntFlag{Name: "retries", EnvVar: "DVM_RETRIES", Value: config.DefaultRetries, Usage: "How many times to retry a download after a network or server error."},
+		cli.StringFlag{Name: "ca-file", EnvVar: "DVM_CA_FILE", Usage: "Trust the certificate authorities in a PEM bundle, in addition to the system's, e.g. for a corporate proxy or an internal mirror."},
+		cli.StringFlag{Name: "client-cert", EnvVar: "DVM_CLIENT_CERT", Usage: "Authenticate with a PEM client certificate, for mirrors that require mutual TLS. Requires --client-key."},
+		cli.StringFlag{Name: "client-key", EnvVar: "DVM_CLIENT_KEY", Usage: "The PEM private key for --client-cert."},
+		cli.StringFlag{Name: "proxy", EnvVar: "DVM_PROXY", Usage: "Send requests through a proxy, instead of using HTTP_PROXY and HTTPS_PROXY."},
+		cli.StringFlag{Name: "no-proxy", EnvVar: "DVM_NO_PROXY", Usage: "A comma separated list of hosts that should not be proxied, instead of using NO_PROXY."},
+		cli.BoolFlag{Name: "insecure-skip-verify", EnvVar: "DVM_INSECURE_SKIP_VERIFY", Usage: "Do not verify server certificates. This is insecure and should only be used for testing."},
+		cli.StringFlag{Name: "mirrors", EnvVar: "DVM_MIRRORS", Usage: "A comma separated list of mirrors to download from, tried in order until one responds. Use default for download.docker.com. Defaults to the mirrors listed in $DVM_DIR/mirrors."},
+		cli.StringFlag{Name: "mirror-strategy", EnvVar: "DVM_MIRROR_STRATEGY", Value: string(mirror.InOrder), Usage: "How to order the mirrors: order tries them as listed, latency tries the fastest first. Mirrors that failed in the last few minutes are tried last."},
+		cli.StringFlag{Name: "mirror-template", EnvVar: "DVM_MIRROR_TEMPLATE", Usage: "The layout of the mirrors, using the placeholders {scheme}, {host}, {prefix}, {os}, {channel}, {arch}, {version} and {ext}. Releases are listed from the directory of the file. Defaults to " + string(mirror.DefaultTemplate) + "."},
+		cli.StringFlag{Name: "mirror-token", EnvVar: "DVM_MIRROR_TOKEN", Usage: "Authenticate with the download mirror using a bearer token."},
+		cli.StringFlag{Name: "mirror-user", EnvVar: "DVM_MIRROR_USER", Usage: "Authenticate with the download mirror using basic authentication. Requires --mirror-password."},
+		cli.StringFlag{Name: "mirror-password", EnvVar: "DVM_MIRROR_PASSWORD", Usage: "The password for --mirror-user."},
+		cli.StringFlag{Name: "netrc-file", EnvVar: "NETRC", Usage: "Look up logins for the download mirror in a netrc file. Defaults to ~/.netrc."},
+		cli.BoolFlag{Name: "netrc-default", EnvVar: "DVM_NETRC_DEFAULT", Usage: "Use the default login in the netrc file for mirrors without a machine entry."},
 	}
 	app.Commands = []cli.Command{
 		{
 			Name:  "detect",
-			Usage: "Detect the appropriate Docker client version",
+			Usage: "dvm detect [--context <context>], dvm detect --all-contexts\n\tDetect the appropriate Docker client version",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "context", Usage: "Detect the client version for the specified docker context. Defaults to the current docker context."},
+				cli.BoolFlag{Name: "all-contexts", Usage: "Detect and install the client version for every docker context."},
+				cli.BoolFlag{Name: "print", Usage: "Only print the detected version, without installing or using it."},
+				cli.StringFlag{Name: "strategy", EnvVar: "DVM_DETECT_STRATEGY", Value: detectExact, Usage: "How to select the client version: exact, highest-compatible or lowest-compatible. Compatible clients speak an API version no newer than the server."},
+				cli.DurationFlag{Name: "timeout", EnvVar: "DVM_DETECT_TIMEOUT", Value: 10 * time.Second, Usage: "How long to wait for the docker daemon to respond, and to list the available versions when none of the installed versions are compatible."},
+				cli.StringFlag{Name: "fallback", EnvVar: "DVM_DETECT_FALLBACK", Usage: "Use the specified version, e.g. default or system, when the docker daemon cannot be reached."},
+			},
 			Action: func(c *cli.Context) error {
 				setGlobalVars(c)
 
-				writeDebug("dvm detect")
-				detect()
+				options := detectOptions{
+					Context:  c.String("context"),
+					Strategy: c.String("strategy"),
+					Timeout:  c.Duration("timeout"),
+					Fallback: c.String("fallback"),
+					Print:    c.Bool("print"),
+				}
+				validateDetectStrategy(options.Strategy)
+
+				if c.Bool("all-contexts") {
+					writeDebug("dvm detect --all-contexts")
+					detectAllContexts(options)
+					return nil
+				}
+
+				writeDebug("dvm detect %s", options.Context)
+				detect(options)
 				return nil
 			},
 		},
 		{
 			Name:    "install",
 			Aliases: []string{"i"},
-			Usage:   "dvm install [<version>], dvm install edge\n\tInstall a Docker version, using $DOCKER_VERSION if the version is not specified.",
+			Usage:   "dvm install [<version>], dvm install edge, dvm install --from-file <file> [--version <version>], dvm install --bin-dir <dir> <version>\n\tInstall a Docker version, using $DOCKER_VERSION if the version is not specified.",
 			Flags: []cli.Flag{
-				cli.StringFlag{Name: "mirror-url", EnvVar: "DVM_MIRROR_URL", Usage: "Specify an alternate URL from which to download the Docker client. Defaults to https://get.docker.com/builds"},
+				cli.StringFlag{Name: "mirror-url", EnvVar: "DVM_MIRROR_URL", Usage: "Specify an alternate mirror from which to download the Docker client, either a host or a URL with an optional path prefix, e.g. https://example.com/docker. Defaults to https://download.docker.com"},
+				cli.BoolFlag{Name: "dry-run", Usage: "Print what would be downloaded and where it would be installed, without installing."},
+				cli.StringFlag{Name: "from-file", Usage: "Install from a local release archive or docker client binary instead of downloading it."},
+				cli.StringFlag{Name: "version", Usage: "The version installed by --from-file. Defaults to the version in the file name, or reported by the docker client."},
+				cli.StringFlag{Name: "checksum-file", Usage: "Verify --from-file against a SHA256 checksum file. Defaults to <file>.sha256 when present."},
+				cli.StringFlag{Name: "bin-dir", Usage: "Install the docker client directly into a directory, e.g. /usr/local/bin, without using it in the current shell."},
+				cli.StringFlag{Name: "name", Usage: "The file name of the docker client installed by --bin-dir. Defaults to docker."},
+				cli.StringFlag{Name: "components", EnvVar: "DVM_COMPONENTS", Usage: "Comma separated components to install from the release archive: client, dockerd, containerd, runc and ctr. Defaults to client."},
+				cli.StringFlag{Name: "arch", Usage: "Install the Docker version built for another architecture, e.g. armhf, to run through qemu/binfmt. Defaults to the current architecture."},
 			},
 			Action: func(c *cli.Context) error {
 				setGlobalVars(c)
 
+				if fromFile := c.String("from-file"); fromFile != "" {
+					for _, flag := range []string{"bin-dir", "dry-run", "components"} {
+						if c.IsSet(flag) {
+							die("The --from-file and --%s flags cannot be combined.", nil, retCodeInvalidArgument, flag)
+						}
+					}
+
+					value := c.String("version")
+					if value == "" {
+						value = c.Args().First()
+					}
+
+					writeDebug("dvm install --from-file %s %s", fromFile, value)
+					installFromFile(fromFile, value, c.String("checksum-file"))
+					return nil
+				}
+
 				value := c.Args().First()
 				if value == "" {
 					value = getDockerVersionVar()
@@ -93,14 +163,56 @@ func makeCliApp() *cli.App {
 					}
 				}
 
+				if c.Bool("dry-run") {
+					var destPath string
+					if binDir := c.String("bin-dir"); binDir != "" {
+						destPath = getDownloadPath(binDir, c.String("name"))
+					}
+					writeDebug("dvm install --dry-run %s", value)
+					installDryRun(dockerversion.Parse(value), destPath)
+					return nil
+				}
+
+				if binDir := c.String("bin-dir"); binDir != "" {
+					writeDebug("dvm install %s --bin-dir %s", value, binDir)
+					downloadToDir(dockerversion.Parse(value), binDir, c.String("name"))
+					return nil
+				}
+
 				writeDebug("dvm install %s", value)
 				install(dockerversion.Parse(value))
 				return nil
 			},
 		},
+		{
+			Name:  "download",
+			Usage: "dvm download [--os <os>] [--arch <arch>] [-o <dir>] <version>\n\tDownload a Docker client, for any platform, into a directory without installing it.",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "os", Usage: "Download the client for another operating system: darwin, linux or windows. Defaults to the current operating system."},
+				cli.StringFlag{Name: "arch", Usage: "Download the client for another architecture, e.g. x86_64 or aarch64. Defaults to the current architecture."},
+				cli.StringFlag{Name: "output, o", Value: ".", Usage: "The directory where the client is saved."},
+				cli.StringFlag{Name: "components", EnvVar: "DVM_COMPONENTS", Usage: "Comma separated components to download from the release archive: client, dockerd, containerd, runc and ctr. Defaults to client."},
+				cli.StringFlag{Name: "mirror-url", EnvVar: "DVM_MIRROR_URL", Usage: "Specify an alternate mirror from which to download the Docker client, either a host or a URL with an optional path prefix, e.g. https://example.com/docker. Defaults to https://download.docker.com"},
+			},
+			Action: func(c *cli.Context) error {
+				setGlobalVars(c)
+
+				value := c.Args().First()
+				if value == "" {
+					die("The download command requires that a version is specified.", nil, retCodeInvalidArgument)
+				}
+
+				writeDebug("dvm download %s --os %s --arch %s -o %s", value, opts.Platform.OS, opts.Platform.Arch, c.String("output"))
+				downloadToDir(dockerversion.Parse(value), c.String("output"), "")
+				return nil
+			},
+		},
 		{
 			Name:  "uninstall",
-			Usage: "dvm uninstall <version>\n\tUninstall a Docker version.",
+			Usage: "dvm uninstall [--arch <arch>] <version>\n\tUninstall a Docker version.",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "arch", Usage: "Uninstall the Docker version installed for another architecture, e.g. armhf. Defaults to the current architecture."},
+			},
 			Action: func(c *cli.Context) error {
 				setGlobalVars(c)
 
@@ -118,8 +230,12 @@ func makeCliApp() *cli.App {
 			Name:  "use",
 			Usage: "dvm use [<version>], dvm use system, dvm use edge\n\tUse a Docker version, using $DOCKER_VERSION if the version is not specified.",
 			Flags: []cli.Flag{
-				cli.StringFlag{Name: "mirror-url", EnvVar: "DVM_MIRROR_URL", Usage: "Specify an alternate URL from which to download the Docker client. Defaults to https://get.docker.com/builds"},
+				cli.StringFlag{Name: "mirror-url", EnvVar: "DVM_MIRROR_URL", Usage: "Specify an alternate mirror from which to download the Docker client, either a host or a URL with an optional path prefix, e.g. https://example.com/docker. Defaults to https://download.docker.com"},
 				cli.BoolFlag{Name: "nocheck", EnvVar: "DVM_NOCHECK", Usage: "Do not check if version exists (use with caution)."},
+				cli.BoolFlag{Name: "check-compat", EnvVar: "DVM_CHECK_COMPAT", Usage: "Warn when the Docker version speaks a newer API than the docker daemon."},
+				cli.BoolFlag{Name: "isolate-config", EnvVar: "DVM_ISOLATE_CONFIG", Usage: "Use a separate DOCKER_CONFIG directory for the Docker version, seeded from ~/.docker/config.json."},
+				cli.StringFlag{Name: "server-api-version", EnvVar: "DVM_SERVER_API_VERSION", Usage: "Pin the API version of the docker daemon, e.g. 1.24. DOCKER_API_VERSION is set when the Docker version speaks a newer API."},
+				cli.StringFlag{Name: "arch", Usage: "Use the Docker version installed for another architecture, e.g. armhf. Defaults to the current architecture."},
 			},
 			Action: func(c *cli.Context) error {
 				setGlobalVars(c)
@@ -138,6 +254,81 @@ func makeCliApp() *cli.App {
 				return nil
 			},
 		},
+		{
+			Name:  "env",
+			Usage: "dvm env set <version> KEY=VALUE..., dvm env unset <version> KEY..., dvm env list <version>\n\tManage the environment variables exported when using a Docker version.",
+			Subcommands: []cli.Command{
+				{
+					Name:  "set",
+					Usage: "dvm env set <version> KEY=VALUE...\n\tExport environment variables when using a Docker version.",
+					Action: func(c *cli.Context) error {
+						setGlobalVars(c)
+
+						value := c.Args().First()
+						if value == "" || len(c.Args()) < 2 {
+							die("The env set command requires a version and at least one KEY=VALUE.", nil, retCodeInvalidArgument)
+						}
+
+						writeDebug("dvm env set %s", value)
+						envSet(value, c.Args().Tail())
+						return nil
+					},
+				},
+				{
+					Name:  "unset",
+					Usage: "dvm env unset <version> KEY...\n\tStop exporting environment variables when using a Docker version.",
+					Action: func(c *cli.Context) error {
+						setGlobalVars(c)
+
+						value := c.Args().First()
+						if value == "" || len(c.Args()) < 2 {
+							die("The env unset command requires a version and at least one KEY.", nil, retCodeInvalidArgument)
+						}
+
+						writeDebug("dvm env unset %s", value)
+						envUnset(value, c.Args().Tail())
+						return nil
+					},
+				},
+				{
+					Name:    "list",
+					Aliases: []string{"ls"},
+					Usage:   "dvm env list <version>\n\tList the environment variables exported when using a Docker version.",
+					Action: func(c *cli.Context) error {
+						setGlobalVars(c)
+
+						value := c.Args().First()
+						if value == "" {
+							die("The env list command requires a version.", nil, retCodeInvalidArgument)
+						}
+
+						writeDebug("dvm env list %s", value)
+						envList(value)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:  "compat",
+			Usage: "dvm compat [--context <context>] [--set-api-version]\n\tCheck that the current Docker version is compatible with the docker daemon.",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "context", Usage: "Check against the specified docker context. Defaults to the current docker context."},
+				cli.DurationFlag{Name: "timeout", Value: 10 * time.Second, Usage: "How long to wait for the docker daemon to respond."},
+				cli.BoolFlag{Name: "set-api-version", Usage: "Set DOCKER_API_VERSION to the daemon's API version when the client is newer."},
+			},
+			Action: func(c *cli.Context) error {
+				setGlobalVars(c)
+
+				writeDebug("dvm compat")
+				compat(compatOptions{
+					Context:       c.String("context"),
+					Timeout:       c.Duration("timeout"),
+					SetAPIVersion: c.Bool("set-api-version"),
+				})
+				return nil
+			},
+		},
 		{
 			Name:  "deactivate",
 			Usage: "dvm deactivate\n\tUndo the effects of `dvm` on current shell.",
@@ -151,12 +342,31 @@ func makeCliApp() *cli.App {
 		},
 		{
 			Name:  "current",
-			Usage: "dvm current\n\tPrint the current Docker version.",
+			Usage: "dvm current [--verbose]\n\tPrint the current Docker version.",
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "verbose", Usage: "Print the API version, Go version, git commit and build time of the client."},
+			},
 			Action: func(c *cli.Context) error {
 				setGlobalVars(c)
 
 				writeDebug("dvm current")
-				current()
+				current(c.Bool("verbose"))
+				return nil
+			},
+		},
+		{
+			Name:  "info",
+			Usage: "dvm info [<version>]\n\tPrint details about a Docker version, defaults to the current version.",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "mirror-url", EnvVar: "DVM_MIRROR_URL", Usage: "Specify an alternate mirror from which to download the Docker client, either a host or a URL with an optional path prefix, e.g. https://example.com/docker. Defaults to https://download.docker.com"},
+			},
+			Action: func(c *cli.Context) error {
+				setGlobalVars(c)
+
+				value := c.Args().First()
+
+				writeDebug("dvm info %s", value)
+				info(value)
 				return nil
 			},
 		},
@@ -188,6 +398,26 @@ func makeCliApp() *cli.App {
 				return nil
 			},
 		},
+		{
+			Name:  "link",
+			Usage: "dvm link <name> <path> [--copy]\n\tRegister an external docker client, such as a locally built binary, as a named version.",
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "copy", Usage: "Copy the docker client instead of symlinking to it."},
+			},
+			Action: func(c *cli.Context) error {
+				setGlobalVars(c)
+
+				name := c.Args().Get(0)
+				binaryPath := c.Args().Get(1)
+				if name == "" || binaryPath == "" {
+					die("The link command requires both a name and the path to a docker client.", nil, retCodeInvalidArgument)
+				}
+
+				writeDebug("dvm link %s %s", name, binaryPath)
+				link(name, binaryPath, c.Bool("copy"))
+				return nil
+			},
+		},
 		{
 			Name:  "unalias",
 			Usage: "dvm unalias <alias>\n\tRemove a Docker version alias.",
@@ -204,6 +434,51 @@ func makeCliApp() *cli.App {
 				return nil
 			},
 		},
+		{
+			Name:  "bind",
+			Usage: "dvm bind <context> <version>\n\tUse a Docker version whenever the client for a docker context is detected.",
+			Action: func(c *cli.Context) error {
+				setGlobalVars(c)
+
+				context := c.Args().Get(0)
+				value := c.Args().Get(1)
+				if context == "" || value == "" {
+					die("The bind command requires both a docker context and a version.", nil, retCodeInvalidArgument)
+				}
+
+				writeDebug("dvm bind %s %s", context, value)
+				bind(context, value)
+				return nil
+			},
+		},
+		{
+			Name:  "unbind",
+			Usage: "dvm unbind <context>\n\tRemove a docker context binding.",
+			Action: func(c *cli.Context) error {
+				setGlobalVars(c)
+
+				context := c.Args().First()
+				if context == "" {
+					die("The unbind command requires a docker context.", nil, retCodeInvalidArgument)
+				}
+
+				writeDebug("dvm unbind %s", context)
+				unbind(context)
+				return nil
+			},
+		},
+		{
+			Name:    "list-bind",
+			Aliases: []string{"ls-bind"},
+			Usage:   "dvm list-bind\n\tList docker context bindings.",
+			Action: func(c *cli.Context) error {
+				setGlobalVars(c)
+
+				writeDebug("dvm list-bind")
+				listBind()
+				return nil
+			},
+		},
 		{
 			Name:    "list",
 			Aliases: []string{"ls"},
@@ -221,9 +496,11 @@ func makeCliApp() *cli.App {
 		{
 			Name:    "list-remote",
 			Aliases: []string{"ls-remote"},
-			Usage:   "dvm list-remote [<prefix>]\n\tList available Docker versions.",
+			Usage:   "dvm list-remote [--os <os>] [--arch <arch>] [<prefix>]\n\tList available Docker versions.",
 			Flags: []cli.Flag{
 				cli.BoolFlag{Name: "pre", Usage: "Include pre-release versions"},
+				cli.StringFlag{Name: "os", Usage: "List versions published for another operating system: darwin, linux or windows. Defaults to the current operating system."},
+				cli.StringFlag{Name: "arch", Usage: "List versions published for another architecture, e.g. x86_64 or aarch64. Defaults to the current architecture."},
 			},
 			Action: func(c *cli.Context) error {
 				setGlobalVars(c)
@@ -283,71 +560,74 @@ func setGlobalVars(c *cli.Context) {
 	validateShellFlag()
 
 	opts.Silent = c.GlobalBool("silent")
-	opts.MirrorURL = c.String("mirror-url")
-	opts.IncludePrereleases = c.Bool("pre")
+	opts.ConnectTimeout = c.GlobalDuration("connect-timeout")
+	opts.ReadTimeout = c.GlobalDuration("read-timeout")
+	opts.Retries = c.GlobalInt("retries")
+	if opts.ConnectTimeout < 0 || opts.ReadTimeout < 0 || opts.Retries < 0 {
+		die("The --connect-timeout, --read-timeout and --retries flags cannot be negative.", nil, retCodeInvalidArgument)
+	}
+
+	httpOpts := httpclient.Options{
+		ConnectTimeout:     opts.ConnectTimeout,
+		ReadTimeout:        opts.ReadTimeout,
+		CAFile:             c.GlobalString("ca-file"),
+		ClientCert:         c.GlobalString("client-cert"),
+		ClientKey:          c.GlobalString("client-key"),
+		Proxy:              c.GlobalString("proxy"),
+		NoProxy:            c.GlobalString("no-proxy"),
+		InsecureSkipVerify: c.GlobalBool("insecure-skip-verify"),
+	}
+	if httpOpts.InsecureSkipVerify {
+		writeWarning("Server certificates are not being verified because --insecure-skip-verify is set.")
+	}
 
 	opts.DvmDir = c.GlobalString("dvm-dir")
 	if opts.DvmDir == "" {
 		opts.DvmDir = filepath.Join(getUserHomeDir(), ".dvm")
 	}
 	writeDebug("The dvm home directory is: %s", opts.DvmDir)
-}
-
-func detect() {
-	writeDebug("dvm detect")
 
-	docker, err := dockerclient.NewEnvClient()
+	mirrors, err := getMirrors(c)
 	if err != nil {
-		die("Cannot build a docker client from environment variables", err, retCodeRuntimeError)
+		die("Invalid mirror list.", err, retCodeInvalidArgument)
 	}
-
-	versionResult, err := docker.ServerVersion(context.Background())
+	mirrors, httpOpts.MirrorAuth, err = buildMirrorAuth(c, mirrors)
 	if err != nil {
-		die("Unable to query docker version", err, retCodeRuntimeError)
+		die("Invalid mirror URL.", err, retCodeInvalidArgument)
+	}
+	opts.HTTPClient, err = httpclient.New(httpOpts)
+	if err != nil {
+		die("Invalid network settings.", err, retCodeInvalidArgument)
 	}
 
-	writeDebug("Queried /version and got Version: %s", versionResult.Version)
-	version := dockerversion.Parse(versionResult.Version)
-
-	// Docker versions prior to 1.12 don't return a usable client version
-	// Lookup the client version from the API version
-	if version.IsEmpty() {
-		writeDebug("Attempting to lookup a client version for API version: %s", versionResult.APIVersion)
-
-		// api version -> client version range
-		oldVersionMap := map[string]string{
-			"1.23": "1.11.x",
-			"1.22": "1.10.x",
-			"1.21": "1.9.x",
-			"1.20": "1.8.x",
-			"1.19": "1.7.x",
-			"1.18": "1.6.x",
-		}
-		clientRange, found := oldVersionMap[versionResult.APIVersion]
-		if !found {
-			die("Unable to detect the proper client version for Docker API version %s", nil, retCodeRuntimeError, versionResult.APIVersion)
-		}
-
-		// Find the highest version that satisfies the client version range
-		availableVersions := getAvailableVersions("", true)
-		for i := len(availableVersions) - 1; i >= 0; i-- {
-			v := availableVersions[i]
-
-			if ok, _ := v.InRange(clientRange); ok {
-				version = v
-				break
-			}
-		}
-		if version.IsEmpty() {
-			die("Unable to detect the proper client version for %s", nil, retCodeRuntimeError, clientRange)
+	strategy, err := mirror.ParseStrategy(c.GlobalString("mirror-strategy"))
+	if err != nil {
+		die("", err, retCodeInvalidArgument)
+	}
+	opts.MirrorTemplate, err = mirror.ParseTemplate(c.GlobalString("mirror-template"))
+	if err != nil {
+		die("", err, retCodeInvalidArgument)
+	}
+	opts.MirrorURL = mirrors[0]
+	opts.Mirrors = mirror.New(mirrors, strategy, getMirrorStatePath(), opts.HTTPClient, opts.Logger)
+	opts.IncludePrereleases = c.Bool("pre")
+	opts.CheckCompat = c.Bool("check-compat")
+	opts.IsolateConfig = c.Bool("isolate-config")
+	opts.ServerAPIVersion = c.String("server-api-version")
+
+	// Commands that download validate the platform, other commands work on any host
+	opts.Platform = platform.Current()
+	if c.String("os") != "" || c.String("arch") != "" {
+		opts.Platform, err = platform.Parse(c.String("os"), c.String("arch"))
+		if err != nil {
+			die("Invalid platform.", err, retCodeInvalidArgument)
 		}
 	}
-	writeDebug("Detected client version: %s", version)
 
-	os.Setenv(versionEnvVar, version.String())
-	writeEnvironmentVariableScript(versionEnvVar)
-
-	use(version)
+	opts.Components, err = dockerversion.ParseComponents(c.String("components"))
+	if err != nil {
+		die("Invalid components.", err, retCodeInvalidArgument)
+	}
 }
 
 func upgrade(checkOnly bool, version string) {
@@ -372,6 +652,7 @@ func upgrade(checkOnly bool, version string) {
 	}
 
 	writeInfo("Upgrading to dvm %s...", version)
+	lockDvmDir()
 	upgradeSelf(version)
 }
 
@@ -381,12 +662,17 @@ func buildDvmReleaseURL(version string, elem ...string) string {
 	return url.Join(prefix, suffix)
 }
 
-func current() {
+func current(verbose bool) {
 	current, err := getCurrentDockerVersion()
 	if err != nil {
 		writeWarning("N/A")
-	} else {
-		writeInfo(current.String())
+		return
+	}
+
+	writeInfo(current.String())
+	if verbose {
+		currentPath, _ := getCurrentDockerPath()
+		writeClientInfo(currentPath)
 	}
 }
 
@@ -394,55 +680,90 @@ func list(pattern string) {
 	pattern += "*"
 	versions := getInstalledVersions(pattern)
 	current, _ := getCurrentDockerVersion()
+	currentPath, _ := getCurrentDockerPath()
+	isCurrentArch := isCurrentDockerArch()
 
 	for _, version := range versions {
-		if current.String() == version.String() {
-			color.Green("->\t%s", version)
+		label := version.String()
+		if isLinkedVersion(version) {
+			label = formatLinkedVersion(version)
+		}
+		label += formatComponents(getVersionDir(version), opts.Platform)
+
+		if isCurrentArch && current.String() == version.String() {
+			color.Green("->\t%s", label)
 		} else {
-			writeInfo("\t%s", version)
+			writeInfo("\t%s", label)
+		}
+	}
+
+	for _, arch := range getInstalledArches() {
+		archPlatform := opts.Platform
+		archPlatform.Arch = arch
+		for _, version := range getInstalledArchVersions(arch, pattern) {
+			versionDir := filepath.Join(getArchVersionsDir(arch), version.Slug())
+			label := fmt.Sprintf("%s [%s]%s", version, arch, formatComponents(versionDir, archPlatform))
+			if filepath.Dir(currentPath) == versionDir {
+				color.Green("->\t%s", label)
+			} else {
+				writeInfo("\t%s", label)
+			}
 		}
 	}
 }
 
 func install(version dockerversion.Version) {
+	lockDvmDir()
 	versionDir := getVersionDir(version)
 
-	if version.IsEdge() {
-		// Always install latest of edge build
-		err := os.RemoveAll(versionDir)
-		if err != nil {
-			die("Unable to remove edge version at %s.", err, retCodeRuntimeError, versionDir)
-		}
-	}
-
-	if _, err := os.Stat(versionDir); err == nil {
+	// Always install latest of edge build
+	if _, err := os.Stat(versionDir); err == nil && !version.IsEdge() {
 		writeWarning("%s is already installed", version)
+		warnMissingComponents(version)
 		use(version)
 		return
 	}
 
 	writeInfo("Installing %s...", version)
 
-	downloadRelease(version)
+	// Assemble the install in a staging directory, so that an interrupted install is never mistaken for a complete one
+	stagingDir := newStagingDir()
+	defer os.RemoveAll(stagingDir)
+	stagedVersionDir := filepath.Join(stagingDir, filepath.Base(versionDir))
+
+	downloadRelease(version, stagedVersionDir)
+	validateRelease(version, stagedVersionDir)
+	saveInstallMetadata(version, stagedVersionDir)
+	commitStagedVersion(version, stagedVersionDir)
 
 	if useAfterInstall {
 		use(version)
 	}
 }
 
-func downloadRelease(version dockerversion.Version) {
-	destPath := filepath.Join(getVersionDir(version), getBinaryName())
-	err := version.Download(opts, destPath)
+func downloadRelease(version dockerversion.Version, versionDir string) {
+	validateDownloadPlatform()
+	destPath := filepath.Join(versionDir, getBinaryName())
+
+	// Keep the downloader's temporary files with the rest of the install
+	downloadOpts := opts
+	downloadOpts.DvmDir = filepath.Dir(versionDir)
+	served, err := version.Download(appCtx, downloadOpts, destPath)
 	if err != nil {
+		if removeErr := os.RemoveAll(versionDir); removeErr != nil {
+			writeWarning("Unable to remove %s: %s", versionDir, removeErr)
+		}
 		die("", err, retCodeRuntimeError)
 	}
+	reportMirror(served)
 
 	writeDebug("Downloaded Docker %s to %s", version, destPath)
 }
 
 func uninstall(version dockerversion.Version) {
+	lockDvmDir()
 	current, _ := getCurrentDockerVersion()
-	if current.Equals(version) {
+	if current.Equals(version) && isCurrentDockerArch() {
 		die("Cannot uninstall the currently active Docker version.", nil, retCodeInvalidOperation)
 	}
 
@@ -452,18 +773,24 @@ func uninstall(version dockerversion.Version) {
 		return
 	}
 
+	// Removing a linked version only removes the link, never the original binary
+	linked := isLinkedVersion(version)
+
 	err := os.RemoveAll(versionDir)
 	if err != nil {
 		die("Unable to uninstall Docker version %s located in %s.", err, retCodeRuntimeError, version, versionDir)
 	}
 
+	if linked {
+		writeInfo("Unlinked %s.", version)
+		return
+	}
 	writeInfo("Uninstalled Docker %s.", version)
 }
 
 func use(version dockerversion.Version) {
 	if version.IsAlias() && aliasExists(version.Name()) {
-		aliasedVersion, _ := ioutil.ReadFile(getAliasPath(version.Name()))
-		version = dockerversion.NewAlias(version.Name(), string(aliasedVersion))
+		version = resolveAlias(version)
 		writeDebug("Using alias: %s", version)
 	}
 
@@ -482,7 +809,20 @@ func use(version dockerversion.Version) {
 	}
 
 	writeEnvironmentVariableScript(pathEnvVar)
-	writeInfo("Now using Docker %s", version)
+	applyProfile(version)
+	exportActivationVariables(version)
+	if opts.Platform.IsCurrentArch() {
+		writeInfo("Now using Docker %s", version)
+	} else {
+		writeInfo("Now using Docker %s [%s]", version, opts.Platform.Arch)
+	}
+
+	if opts.CheckCompat {
+		_, err := checkCompatibility(version, compatOptions{Timeout: useCompatTimeout})
+		if err != nil {
+			writeDebug("Skipping the compatibility check: %s", err)
+		}
+	}
 }
 
 func which() {
@@ -493,7 +833,13 @@ func which() {
 }
 
 func alias(alias string, value string) {
+	lockDvmDir()
 	version := dockerversion.NewAlias(alias, value)
+	aliasedValue := version.Value()
+	if linkedVersion := dockerversion.Parse(value); isLinkedVersion(linkedVersion) {
+		version = linkedVersion
+		aliasedValue = linkedVersion.Name()
+	}
 	if !isVersionInstalled(version) {
 		die("The aliased version, %s, is not installed.", nil, retCodeInvalidArgument, version)
 	}
@@ -503,11 +849,12 @@ func alias(alias string, value string) {
 		writeDebug("Overwriting existing alias.")
 	}
 
-	writeFile(aliasPath, version.Value())
+	replaceFile(aliasPath, aliasedValue)
 	writeInfo("Aliased %s to %s.", alias, value)
 }
 
 func unalias(alias string) {
+	lockDvmDir()
 	if !aliasExists(alias) {
 		writeWarning("%s is not an alias.", alias)
 		return
@@ -567,6 +914,7 @@ func getBinaryName() string {
 func deactivate() {
 	removePreviousDockerVersionFromPath()
 	writeEnvironmentVariableScript(pathEnvVar)
+	restoreEnvironment()
 }
 
 func prependDockerVersionToPath(version dockerversion.Version) {
@@ -594,7 +942,10 @@ func buildDvmOutputScriptPath() string {
 }
 
 func removePreviousDockerVersionFromPath() {
-	removePath(getCleanPathRegex())
+	versionsDir := getArchVersionsDir("")
+	removePath(func(entry string) bool {
+		return isPathWithin(entry, versionsDir) || isOtherArchPath(entry)
+	})
 }
 
 func ensureVersionIsInstalled(version dockerversion.Version) {
@@ -631,6 +982,11 @@ func getCurrentDockerVersion() (dockerversion.Version, error) {
 		return dockerversion.Version{}, err
 	}
 
+	if linkedVersion, ok := getLinkedVersion(currentDockerPath); ok {
+		writeDebug("The current docker is linked as %s", linkedVersion)
+		return linkedVersion, nil
+	}
+
 	systemDockerPath, _ := getSystemDockerPath()
 	edgeVersionPath, _ := getEdgeDockerPath()
 
@@ -688,21 +1044,14 @@ func getEdgeDockerVersion() (dockerversion.Version, error) {
 }
 
 func getDockerVersion(dockerPath string, includeBuild bool) (dockerversion.Version, error) {
-	stdout, _ := exec.Command(dockerPath, "-v").Output()
-	rawVersion := strings.TrimSpace(string(stdout))
-
-	writeDebug("%s -v output: %s", dockerPath, rawVersion)
-
-	versionRegex := regexp.MustCompile(`^Docker version (.+), build (.+)?`)
-	match := versionRegex.FindStringSubmatch(rawVersion)
-	if len(match) < 2 {
-		return dockerversion.Version{}, errors.New("Could not detect docker version.")
+	info, err := readDockerVersionBanner(dockerPath)
+	if err != nil {
+		return dockerversion.Version{}, err
 	}
 
-	version := string(match[1][:])
-	build := string(match[2][:])
-	if includeBuild {
-		version = fmt.Sprintf("%s+%s", version, build)
+	version := info.Version
+	if includeBuild && info.GitCommit != "" {
+		version = fmt.Sprintf("%s+%s", version, info.GitCommit)
 	}
 	return dockerversion.Parse(version), nil
 }
@@ -747,12 +1096,25 @@ func getInstalledVersions(pattern string) []dockerversion.Version {
 }
 
 func getAvailableVersions(pattern string, includePrereleases bool) []dockerversion.Version {
+	versions, err := listAvailableVersions(appCtx, pattern, includePrereleases)
+	if err != nil {
+		die("", err, retCodeRuntimeError)
+	}
+	return versions
+}
+
+// List the versions which can be installed, stopping when ctx is done
+func listAvailableVersions(ctx context.Context, pattern string, includePrereleases bool) ([]dockerversion.Version, error) {
+	if err := checkDownloadPlatform(); err != nil {
+		return nil, err
+	}
+
 	versions := make(map[string]dockerversion.Version)
 
 	writeDebug("Retrieving legacy Docker releases")
-	legacyVersions, err := listLegacyDockerVersions()
+	legacyVersions, err := listLegacyDockerVersions(ctx)
 	if err != nil {
-		die("", err, retCodeRuntimeError)
+		return nil, err
 	}
 	for _, v := range legacyVersions {
 		if !includePrereleases && v.IsPrerelease() {
@@ -764,9 +1126,9 @@ func getAvailableVersions(pattern string, includePrereleases bool) []dockerversi
 	}
 
 	writeDebug("Retrieving Docker releases")
-	stableVersions, err := dockerversion.ListVersions(opts.MirrorURL, dockerversion.Stable)
+	stableVersions, err := dockerversion.ListVersions(ctx, opts, dockerversion.Stable)
 	if err != nil {
-		die("", err, retCodeRuntimeError)
+		return nil, err
 	}
 	for _, v := range stableVersions {
 		if strings.HasPrefix(v.Value(), pattern) {
@@ -776,9 +1138,9 @@ func getAvailableVersions(pattern string, includePrereleases bool) []dockerversi
 
 	if includePrereleases {
 		writeDebug("Retrieving Docker pre-releases")
-		prereleaseVersions, err := dockerversion.ListVersions(opts.MirrorURL, dockerversion.Test)
+		prereleaseVersions, err := dockerversion.ListVersions(ctx, opts, dockerversion.Test)
 		if err != nil {
-			die("", err, retCodeRuntimeError)
+			return nil, err
 		}
 		for _, v := range prereleaseVersions {
 			if strings.HasPrefix(v.Value(), pattern) {
@@ -794,11 +1156,11 @@ func getAvailableVersions(pattern string, includePrereleases bool) []dockerversi
 
 	dockerversion.Sort(results)
 
-	return results
+	return results, nil
 }
 
-func listLegacyDockerVersions() ([]dockerversion.Version, error) {
-	gh := buildGithubClient()
+func listLegacyDockerVersions(ctx context.Context) ([]dockerversion.Version, error) {
+	gh := buildGithubClient(ctx)
 	options := &github.ListOptions{PerPage: 100}
 
 	var allReleases []github.RepositoryRelease
@@ -833,7 +1195,7 @@ func listLegacyDockerVersions() ([]dockerversion.Version, error) {
 }
 
 func isUpgradeAvailable() (bool, string) {
-	gh := buildGithubClient()
+	gh := buildGithubClient(appCtx)
 	release, response, err := gh.Repositories.GetLatestRelease("howtowhale", "dvm")
 	if err != nil {
 		warnWhenRateLimitExceeded(err, response)
@@ -863,7 +1225,7 @@ func isUpgradeAvailable() (bool, string) {
 }
 
 func getVersionsDir() string {
-	return filepath.Join(opts.DvmDir, "bin", "docker")
+	return getArchVersionsDir(opts.Platform.Arch)
 }
 
 func getVersionDir(version dockerversion.Version) string {
@@ -878,14 +1240,18 @@ func getDockerVersionVar() string {
 	return strings.TrimSpace(os.Getenv("DOCKER_VERSION"))
 }
 
-func buildGithubClient() *github.Client {
+// Build a github client whose requests are cancelled along with ctx
+func buildGithubClient(ctx context.Context) *github.Client {
+	httpClient := *opts.HTTPClient
+	httpClient.Transport = contextTransport{ctx: ctx, base: httpClient.Transport}
+
 	if opts.Token != "" {
 		tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: opts.Token})
-		httpClient := oauth2.NewClient(oauth2.NoContext, tokenSource)
-		return github.NewClient(httpClient)
+		oauthCtx := context.WithValue(oauth2.NoContext, oauth2.HTTPClient, &httpClient)
+		return github.NewClient(oauth2.NewClient(oauthCtx, tokenSource))
 	}
 
-	client := github.NewClient(nil)
+	client := github.NewClient(&httpClient)
 	if githubUrlOverride != "" {
 		var err error
 		client.BaseURL, err = neturl.Parse(githubUrlOverride)
@@ -896,6 +1262,20 @@ func buildGithubClient() *github.Client {
 	return client
 }
 
+// contextTransport binds requests to a context, since the github client predates request contexts
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(r.WithContext(t.ctx))
+}
+
 func warnWhenRateLimitExceeded(err error, response *github.Response) {
 	if err == nil {
 		return
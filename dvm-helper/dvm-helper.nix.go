@@ -11,28 +11,29 @@ import (
 )
 
 const binaryFileExt string = ""
+const netrcFileName string = ".netrc"
 
 func upgradeSelf(version string) {
 	d := downloader.New(opts)
 
 	binaryURL := buildDvmReleaseURL(version, dvmOS, dvmArch, "dvm-helper")
 	binaryPath := filepath.Join(opts.DvmDir, "dvm-helper", "dvm-helper")
-	err := d.DownloadFileWithChecksum(binaryURL, binaryPath)
+	err := d.DownloadFileWithChecksum(appCtx, binaryURL, binaryPath)
 	if err != nil {
 		die("", err, retCodeRuntimeError)
 	}
 
 	scriptURL := buildDvmReleaseURL(version, "dvm.sh")
 	scriptPath := filepath.Join(opts.DvmDir, "dvm.sh")
-	err = d.DownloadFile(scriptURL, scriptPath)
+	err = d.DownloadFile(appCtx, scriptURL, scriptPath)
 	if err != nil {
 		die("", err, retCodeRuntimeError)
 	}
 }
 
-func getCleanPathRegex() string {
-	versionDir := getVersionsDir()
-	return versionDir + `/[^:]+:`
+// Clean a path so that it can be compared with other paths
+func normalizePath(path string) string {
+	return filepath.Clean(path)
 }
 
 func validateShellFlag() {
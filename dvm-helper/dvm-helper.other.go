@@ -0,0 +1,9 @@
+//go:build !386 && !amd64 && !arm && !arm64 && !ppc64le && !s390x
+// +build !386,!amd64,!arm,!arm64,!ppc64le,!s390x
+
+package main
+
+import "runtime"
+
+// dvm-helper isn't released for the other architectures, so only builds from source use this
+const dvmArch string = runtime.GOARCH
@@ -0,0 +1,6 @@
+//go:build ppc64le
+// +build ppc64le
+
+package main
+
+const dvmArch string = "ppc64le"
@@ -0,0 +1,6 @@
+//go:build s390x
+// +build s390x
+
+package main
+
+const dvmArch string = "s390x"
@@ -14,27 +14,28 @@ import (
 
 const dvmOS string = "Windows"
 const binaryFileExt string = ".exe"
+const netrcFileName string = "_netrc"
 
 func upgradeSelf(version string) {
 	d := downloader.New(opts)
 
 	binaryURL := buildDvmReleaseURL(version, dvmOS, dvmArch, "dvm-helper.exe")
 	binaryPath := filepath.Join(opts.DvmDir, ".tmp", "dvm-helper.exe")
-	err := d.DownloadFileWithChecksum(binaryURL, binaryPath)
+	err := d.DownloadFileWithChecksum(appCtx, binaryURL, binaryPath)
 	if err != nil {
 		die("", err, retCodeRuntimeError)
 	}
 
 	psScriptURL := buildDvmReleaseURL(version, "dvm.ps1")
 	psScriptPath := filepath.Join(opts.DvmDir, "dvm.ps1")
-	err = d.DownloadFile(psScriptURL, psScriptPath)
+	err = d.DownloadFile(appCtx, psScriptURL, psScriptPath)
 	if err != nil {
 		die("", err, retCodeRuntimeError)
 	}
 
 	cmdScriptURL := buildDvmReleaseURL(version, "dvm.cmd")
 	cmdScriptPath := filepath.Join(opts.DvmDir, "dvm.cmd")
-	err = d.DownloadFile(cmdScriptURL, cmdScriptPath)
+	err = d.DownloadFile(appCtx, cmdScriptURL, cmdScriptPath)
 	if err != nil {
 		die("", err, retCodeRuntimeError)
 	}
@@ -57,10 +58,9 @@ func writeUpgradeScript() {
 	writeFile(scriptPath, contents)
 }
 
-func getCleanPathRegex() string {
-	versionDir := getVersionsDir()
-	escapedVersionDir := strings.Replace(versionDir, `\`, `\\`, -1)
-	return escapedVersionDir + `\\[^:]+;`
+// Clean a path so that it can be compared with other paths, ignoring case
+func normalizePath(path string) string {
+	return strings.ToLower(filepath.Clean(path))
 }
 
 func validateShellFlag() {
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -54,6 +55,22 @@ func githubReleasesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// setupTestDvmDir points opts.DvmDir at a temporary directory, which is removed and
+// the previous value restored when the test completes
+func setupTestDvmDir(t *testing.T) string {
+	dvmDir, err := ioutil.TempDir("", "dvmtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldDvmDir := opts.DvmDir
+	t.Cleanup(func() {
+		opts.DvmDir = oldDvmDir
+		os.RemoveAll(dvmDir)
+	})
+	opts.DvmDir = dvmDir
+	return dvmDir
+}
+
 func createMockDVM(dockerHandler requestHandler) (docker *httptest.Server, github *httptest.Server) {
 	github = httptest.NewServer(http.HandlerFunc(githubReleasesHandler))
 	githubUrlOverride = github.URL + "/"
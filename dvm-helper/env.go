@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
+)
+
+// snapshotEnvVar holds the values that environment variables had before dvm changed them,
+// so that deactivate can restore the shell. Variables which were not set are recorded as null.
+const snapshotEnvVar string = "DVM_SNAPSHOT"
+
+// These describe the active version for prompts and scripts
+const currentVersionEnvVar string = "DVM_CURRENT_VERSION"
+const currentPathEnvVar string = "DVM_CURRENT_PATH"
+
+type envSnapshot map[string]*string
+
+func loadSnapshot() envSnapshot {
+	snapshot := envSnapshot{}
+
+	encoded := os.Getenv(snapshotEnvVar)
+	if encoded == "" {
+		return snapshot
+	}
+
+	contents, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err == nil {
+		err = json.Unmarshal(contents, &snapshot)
+	}
+	if err != nil {
+		writeDebug("Ignoring invalid %s: %s", snapshotEnvVar, err)
+		return envSnapshot{}
+	}
+
+	return snapshot
+}
+
+func saveSnapshot(snapshot envSnapshot) {
+	if len(snapshot) == 0 {
+		os.Unsetenv(snapshotEnvVar)
+	} else {
+		// Encode the snapshot so that it doesn't need to be escaped in any shell
+		contents, _ := json.Marshal(snapshot)
+		os.Setenv(snapshotEnvVar, base64.RawURLEncoding.EncodeToString(contents))
+	}
+	writeEnvironmentVariableScript(snapshotEnvVar)
+}
+
+// Record the current value of an environment variable, unless dvm already changed it
+func recordEnvironmentVariable(snapshot envSnapshot, name string) {
+	if _, recorded := snapshot[name]; recorded {
+		return
+	}
+
+	if value, isSet := os.LookupEnv(name); isSet {
+		snapshot[name] = &value
+	} else {
+		snapshot[name] = nil
+	}
+}
+
+// Set an environment variable in the calling shell, remembering its original value
+func setEnvironmentVariable(name string, value string) {
+	snapshot := loadSnapshot()
+	recordEnvironmentVariable(snapshot, name)
+	saveSnapshot(snapshot)
+
+	os.Setenv(name, value)
+	writeEnvironmentVariableScript(name)
+}
+
+// Unset an environment variable in the calling shell, remembering its original value
+func unsetEnvironmentVariable(name string) {
+	snapshot := loadSnapshot()
+	recordEnvironmentVariable(snapshot, name)
+	saveSnapshot(snapshot)
+
+	os.Unsetenv(name)
+	writeEnvironmentVariableScript(name)
+}
+
+// Restore every environment variable changed by dvm to its original value
+func restoreEnvironment() {
+	snapshot := loadSnapshot()
+
+	names := make([]string, 0, len(snapshot))
+	for name := range snapshot {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		restoreFromSnapshot(snapshot, name)
+	}
+
+	saveSnapshot(envSnapshot{})
+}
+
+// Restore a single environment variable changed by dvm to its original value
+func restoreEnvironmentVariable(name string) {
+	snapshot := loadSnapshot()
+	if _, recorded := snapshot[name]; !recorded {
+		return
+	}
+
+	restoreFromSnapshot(snapshot, name)
+	delete(snapshot, name)
+	saveSnapshot(snapshot)
+}
+
+// Get the value of an environment variable before dvm changed it
+func getOriginalEnvironmentVariable(name string) string {
+	if value, recorded := loadSnapshot()[name]; recorded {
+		if value == nil {
+			return ""
+		}
+		return *value
+	}
+	return os.Getenv(name)
+}
+
+func restoreFromSnapshot(snapshot envSnapshot, name string) {
+	if value := snapshot[name]; value != nil {
+		writeDebug("Restoring %s", name)
+		os.Setenv(name, *value)
+	} else {
+		writeDebug("Unsetting %s", name)
+		os.Unsetenv(name)
+	}
+	writeEnvironmentVariableScript(name)
+}
+
+// Export the variables which describe the active version, and DOCKER_API_VERSION
+// when the version speaks a newer API than the pinned server API version
+func exportActivationVariables(version dockerversion.Version) {
+	dockerPath := filepath.Join(getVersionDir(version), getBinaryName())
+	if version.IsSystem() {
+		dockerPath, _ = getSystemDockerPath()
+	}
+	setEnvironmentVariable(currentVersionEnvVar, version.String())
+	setEnvironmentVariable(currentPathEnvVar, dockerPath)
+
+	// A DOCKER_API_VERSION from the version's profile takes precedence
+	for _, key := range strings.Split(os.Getenv(profileEnvVar), ",") {
+		if key == apiVersionEnvVar {
+			return
+		}
+	}
+
+	// Drop the DOCKER_API_VERSION set for the previous version
+	restoreEnvironmentVariable(apiVersionEnvVar)
+
+	clientAPIVersion := version.APIVersion()
+	if opts.ServerAPIVersion == "" || clientAPIVersion == "" {
+		return
+	}
+	if dockerversion.CompareAPIVersions(clientAPIVersion, opts.ServerAPIVersion) > 0 {
+		writeDebug("Docker %s speaks API version %s, downgrading to the pinned server API version %s", version, clientAPIVersion, opts.ServerAPIVersion)
+		setEnvironmentVariable(apiVersionEnvVar, opts.ServerAPIVersion)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestoreEnvironment(t *testing.T) {
+	setupEnvironmentTest(t)
+
+	t.Setenv("DVM_TEST_EXISTING", "original")
+	t.Setenv("DVM_TEST_NEW", "")
+	os.Unsetenv("DVM_TEST_NEW")
+
+	setEnvironmentVariable("DVM_TEST_EXISTING", "first")
+	setEnvironmentVariable("DVM_TEST_EXISTING", "second")
+	setEnvironmentVariable("DVM_TEST_NEW", "value")
+	assert.Equal(t, "second", os.Getenv("DVM_TEST_EXISTING"))
+	assert.NotEmpty(t, os.Getenv(snapshotEnvVar), "The original values should be recorded")
+
+	restoreEnvironment()
+
+	assert.Equal(t, "original", os.Getenv("DVM_TEST_EXISTING"), "The variable should be restored to its value before dvm changed it")
+	_, isSet := os.LookupEnv("DVM_TEST_NEW")
+	assert.False(t, isSet, "A variable which did not exist before dvm set it should be unset")
+	_, isSet = os.LookupEnv(snapshotEnvVar)
+	assert.False(t, isSet, "The snapshot should be cleared")
+
+	script, _ := ioutil.ReadFile(buildDvmOutputScriptPath())
+	assert.Contains(t, string(script), "export DVM_TEST_EXISTING=\"original\"\n")
+	assert.Contains(t, string(script), "unset DVM_TEST_NEW\n")
+}
+
+// Use a temporary dvm directory and restore the options and variables changed by dvm
+func setupEnvironmentTest(t *testing.T) {
+	setupTestDvmDir(t)
+
+	oldShell, oldServerAPIVersion := opts.Shell, opts.ServerAPIVersion
+	t.Cleanup(func() { opts.Shell, opts.ServerAPIVersion = oldShell, oldServerAPIVersion })
+	opts.Shell = "sh"
+
+	for _, name := range []string{currentVersionEnvVar, currentPathEnvVar, apiVersionEnvVar, profileEnvVar, snapshotEnvVar} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+func TestExportActivationVariables(t *testing.T) {
+	setupEnvironmentTest(t)
+
+	version := dockerversion.Parse("20.10.24")
+	exportActivationVariables(version)
+
+	assert.Equal(t, "20.10.24", os.Getenv(currentVersionEnvVar))
+	assert.Equal(t, filepath.Join(getVersionDir(version), getBinaryName()), os.Getenv(currentPathEnvVar))
+	_, isSet := os.LookupEnv(apiVersionEnvVar)
+	assert.False(t, isSet, "DOCKER_API_VERSION should not be set without --server-api-version")
+
+	script, _ := ioutil.ReadFile(buildDvmOutputScriptPath())
+	assert.Contains(t, string(script), "export DVM_CURRENT_VERSION=\"20.10.24\"\n")
+}
+
+func TestExportActivationVariables_ServerAPIVersion(t *testing.T) {
+	setupEnvironmentTest(t)
+	opts.ServerAPIVersion = "1.40"
+
+	// Docker 20.10 speaks 1.41, so it is downgraded to the pinned version
+	exportActivationVariables(dockerversion.Parse("20.10.24"))
+	assert.Equal(t, "1.40", os.Getenv(apiVersionEnvVar))
+
+	// Docker 19.03 speaks 1.40, so the pin set for the previous version is dropped
+	exportActivationVariables(dockerversion.Parse("19.03.15"))
+	_, isSet := os.LookupEnv(apiVersionEnvVar)
+	assert.False(t, isSet, "DOCKER_API_VERSION should be removed for a client which is no newer than the server")
+	assert.Equal(t, "19.03.15", os.Getenv(currentVersionEnvVar))
+}
+
+func TestExportActivationVariables_ProfileAPIVersion(t *testing.T) {
+	setupEnvironmentTest(t)
+	opts.ServerAPIVersion = "1.40"
+	os.Setenv(profileEnvVar, apiVersionEnvVar)
+	os.Setenv(apiVersionEnvVar, "1.24")
+
+	exportActivationVariables(dockerversion.Parse("20.10.24"))
+	assert.Equal(t, "1.24", os.Getenv(apiVersionEnvVar), "A DOCKER_API_VERSION from the profile should take precedence over --server-api-version")
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
+	"github.com/howtowhale/dvm/dvm-helper/internal/metadata"
+)
+
+// How long to wait for `docker version`, which also contacts the daemon
+const inspectClientTimeout = 5 * time.Second
+
+// Inspect a docker client binary, preferring `docker version --format`
+// and falling back to parsing `docker -v` for clients which don't support it
+func inspectDockerClient(dockerPath string) (metadata.ClientInfo, error) {
+	ctx, cancel := context.WithTimeout(appCtx, inspectClientTimeout)
+	defer cancel()
+
+	// docker version exits with an error when the daemon is unavailable, but still prints the client details.
+	// The client reports DOCKER_API_VERSION as its API version, so leave it out to get the client's own.
+	cmd := exec.CommandContext(ctx, dockerPath, "version", "--format", "{{json .Client}}")
+	cmd.Env = withoutEnvironmentVariable(os.Environ(), apiVersionEnvVar)
+	stdout, _ := cmd.Output()
+	writeDebug("%s version output: %s", dockerPath, strings.TrimSpace(string(stdout)))
+
+	info, err := metadata.ParseClientInfo(stdout)
+	if err == nil {
+		return info, nil
+	}
+	writeDebug("Falling back to %s -v: %s", dockerPath, err)
+
+	return readDockerVersionBanner(dockerPath)
+}
+
+// Read the version of a docker client from `docker -v`, which never contacts the daemon
+func readDockerVersionBanner(dockerPath string) (metadata.ClientInfo, error) {
+	ctx, cancel := context.WithTimeout(appCtx, inspectClientTimeout)
+	defer cancel()
+
+	stdout, _ := exec.CommandContext(ctx, dockerPath, "-v").Output()
+	rawVersion := strings.TrimSpace(string(stdout))
+	writeDebug("%s -v output: %s", dockerPath, rawVersion)
+
+	return metadata.ParseVersionBanner(rawVersion)
+}
+
+// Get the path to the docker client for a version, or an empty string when it is not installed
+func getVersionDockerPath(version dockerversion.Version) string {
+	switch {
+	case version.IsSystem():
+		dockerPath, _ := getSystemDockerPath()
+		return dockerPath
+	case version.IsEdge():
+		dockerPath, _ := getEdgeDockerPath()
+		return dockerPath
+	}
+
+	dockerPath := filepath.Join(getVersionDir(version), getBinaryName())
+	if _, err := os.Stat(dockerPath); err != nil {
+		return ""
+	}
+	return dockerPath
+}
+
+func withoutEnvironmentVariable(env []string, name string) []string {
+	var results []string
+	for _, value := range env {
+		if !strings.HasPrefix(value, name+"=") {
+			results = append(results, value)
+		}
+	}
+	return results
+}
+
+// Record details about a newly installed version in its version directory
+func saveInstallMetadata(version dockerversion.Version, versionDir string) {
+	m := metadata.Install{
+		Version:     version.Value(),
+		InstalledAt: time.Now().UTC(),
+	}
+
+	info, err := inspectDockerClient(filepath.Join(versionDir, getBinaryName()))
+	if err != nil {
+		writeDebug("Unable to inspect Docker %s: %s", version, err)
+	} else {
+		m.Client = info
+	}
+
+	if err = m.Save(versionDir); err != nil {
+		writeWarning("Unable to save the install metadata for Docker %s: %s", version, err)
+	}
+}
+
+func info(value string) {
+	var version dockerversion.Version
+	var err error
+	if value == "" {
+		version, err = getCurrentDockerVersion()
+		if err != nil {
+			die("No Docker version is currently in use.", err, retCodeInvalidOperation)
+		}
+	} else {
+		version = resolveAlias(dockerversion.Parse(value))
+	}
+
+	writeInfo("Version:\t%s", version)
+
+	if isVersionInstalled(version) {
+		dockerPath := getVersionDockerPath(version)
+		writeClientInfo(dockerPath)
+
+		if !version.IsSystem() {
+			m, err := metadata.Load(filepath.Dir(dockerPath))
+			if err != nil {
+				writeDebug("%s", err)
+			} else {
+				writeInfo("Installed:\t%s", m.InstalledAt.Local().Format(time.RFC1123))
+				writeOptionalInfo("Linked to:\t%s", m.Link)
+			}
+		}
+	} else {
+		writeInfo("Installed:\tno")
+	}
+
+	if aliases := getAliasesFor(version); len(aliases) > 0 {
+		writeInfo("Aliases:\t%s", strings.Join(aliases, ", "))
+	}
+
+	if version.IsSystem() || (version.IsEmpty() && !version.IsEdge()) {
+		return
+	}
+
+	if err := checkDownloadPlatform(); err != nil {
+		writeWarning("%s", err)
+		return
+	}
+
+	release, err := version.Inspect(appCtx, opts)
+	if err != nil {
+		writeWarning("%s", err)
+		return
+	}
+	writeInfo("URL:\t\t%s", release.URL)
+	writeInfo("Channel:\t%s", release.Channel)
+	if release.Size >= 0 {
+		writeInfo("Size:\t\t%s", formatSize(release.Size))
+	}
+	if !release.LastModified.IsZero() {
+		writeInfo("Released:\t%s", release.LastModified.Local().Format(time.RFC1123))
+	}
+	if release.Checksumed {
+		writeInfo("Checksum:\tpublished at %s.sha256", release.URL)
+	} else {
+		writeInfo("Checksum:\tnot published")
+	}
+}
+
+// Print what installing a version would download, without downloading it
+// destPath - where --bin-dir would save the client, defaults to the version directory in DVM_DIR
+func installDryRun(version dockerversion.Version, destPath string) {
+	validateDownloadPlatform()
+	candidates, err := version.DownloadCandidates(appCtx, opts)
+	if err != nil {
+		die("", err, retCodeRuntimeError)
+	}
+
+	writeInfo("Version:\t%s", version)
+	writeInfo("Channel:\t%s", candidates[0].Channel)
+	writeInfo("URL:\t\t%s", candidates[0].URL)
+	for _, candidate := range candidates[1:] {
+		writeInfo("Fallback URL:\t%s (%s)", candidate.URL, candidate.Channel)
+	}
+	if candidates[0].Checksumed {
+		writeInfo("Checksum:\tverified against %s.sha256", candidates[0].URL)
+	} else {
+		writeInfo("Checksum:\tnot published")
+	}
+	if destPath != "" {
+		writeInfo("Destination:\t%s", destPath)
+		if _, err := os.Stat(destPath); err == nil {
+			writeWarning("%s already exists and would be replaced.", destPath)
+		}
+		return
+	}
+
+	writeInfo("Destination:\t%s", filepath.Join(getVersionDir(version), getBinaryName()))
+	if !version.IsEdge() && isVersionInstalled(version) {
+		writeWarning("%s is already installed, nothing would be downloaded.", version)
+	}
+}
+
+// Get the aliases which point to a version
+func getAliasesFor(version dockerversion.Version) []string {
+	target := version.Value()
+	if isLinkedVersion(version) {
+		target = version.Name()
+	}
+
+	var results []string
+	for alias, value := range getAliases() {
+		if value == target {
+			results = append(results, alias)
+		}
+	}
+	sort.Strings(results)
+	return results
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value := float64(size)
+	units := []string{"KiB", "MiB", "GiB"}
+	i := -1
+	for value >= unit && i < len(units)-1 {
+		value /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
+
+// Print the details of a docker client, preferring the saved install metadata
+func writeClientInfo(dockerPath string) {
+	clientInfo, err := inspectDockerClient(dockerPath)
+	if err != nil {
+		if m, loadErr := metadata.Load(filepath.Dir(dockerPath)); loadErr == nil {
+			clientInfo = m.Client
+		} else {
+			writeWarning("Unable to inspect %s: %s", dockerPath, err)
+			return
+		}
+	}
+
+	// Clients which don't support --format don't report their API version
+	if clientInfo.APIVersion == "" {
+		clientInfo.APIVersion = dockerversion.Parse(clientInfo.Version).APIVersion()
+	}
+
+	writeInfo("Path:\t\t%s", dockerPath)
+	writeOptionalInfo("API version:\t%s", clientInfo.APIVersion)
+	writeOptionalInfo("Go version:\t%s", clientInfo.GoVersion)
+	writeOptionalInfo("Git commit:\t%s", clientInfo.GitCommit)
+	writeOptionalInfo("Built:\t\t%s", clientInfo.BuildTime)
+	if clientInfo.Os != "" {
+		writeInfo("OS/Arch:\t%s/%s", clientInfo.Os, clientInfo.Arch)
+	}
+}
+
+func writeOptionalInfo(format string, value string) {
+	if value != "" {
+		writeInfo(format, value)
+	}
+}
+
+// Replace an alias with the version that it points to
+func resolveAlias(version dockerversion.Version) dockerversion.Version {
+	if version.IsAlias() && aliasExists(version.Name()) {
+		aliasedVersion, _ := ioutil.ReadFile(getAliasPath(version.Name()))
+		if linkedVersion := dockerversion.Parse(string(aliasedVersion)); isLinkedVersion(linkedVersion) {
+			return linkedVersion
+		}
+		return dockerversion.NewAlias(version.Name(), string(aliasedVersion))
+	}
+	return version
+}
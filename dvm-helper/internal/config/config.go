@@ -3,21 +3,49 @@ package config
 import (
 	"io/ioutil"
 	"log"
+	"net/http"
+	"time"
+
+	"github.com/howtowhale/dvm/dvm-helper/internal/mirror"
+	"github.com/howtowhale/dvm/dvm-helper/internal/platform"
+)
+
+// Defaults for talking to download mirrors and github
+const (
+	DefaultConnectTimeout = 30 * time.Second
+	DefaultReadTimeout    = 60 * time.Second
+	DefaultRetries        = 3
 )
 
 type DvmOptions struct {
 	DvmDir             string
 	MirrorURL          string
+	Mirrors            *mirror.List
+	MirrorTemplate     mirror.Template
 	Token              string
 	Shell              string
 	Debug              bool
 	Silent             bool
 	IncludePrereleases bool
+	CheckCompat        bool
+	IsolateConfig      bool
+	ServerAPIVersion   string
+	Platform           platform.Platform
+	Components         []string
+	ConnectTimeout     time.Duration
+	ReadTimeout        time.Duration
+	Retries            int
+	HTTPClient         *http.Client
 	Logger             *log.Logger
 }
 
 func NewDvmOptions() DvmOptions {
 	return DvmOptions{
-		Logger: log.New(ioutil.Discard, "", log.LstdFlags),
+		Platform:       platform.Current(),
+		ConnectTimeout: DefaultConnectTimeout,
+		ReadTimeout:    DefaultReadTimeout,
+		Retries:        DefaultRetries,
+		HTTPClient:     http.DefaultClient,
+		Logger:         log.New(ioutil.Discard, "", log.LstdFlags),
 	}
 }
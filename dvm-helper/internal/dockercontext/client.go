@@ -0,0 +1,79 @@
+package dockercontext
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+)
+
+// NewClient builds a Docker API client for the context's endpoint.
+// The API version is not negotiated, so that the client can query any daemon,
+// unless DOCKER_API_VERSION is set.
+func (c Context) NewClient() (*client.Client, error) {
+	apiVersion := os.Getenv("DOCKER_API_VERSION")
+
+	if strings.HasPrefix(c.Endpoint.Host, "ssh://") {
+		transport, err := newSSHTransport(c.Endpoint.Host)
+		if err != nil {
+			return nil, err
+		}
+		// The ssh transport ignores the address, it only needs to be a valid tcp host
+		return client.NewClient("tcp://docker", apiVersion, &http.Client{Transport: transport}, nil)
+	}
+
+	var httpClient *http.Client
+	if c.Endpoint.TLSDir != "" {
+		tlsConfig, err := c.Endpoint.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		httpClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		}
+	}
+
+	cli, err := client.NewClient(c.Endpoint.Host, apiVersion, httpClient, nil)
+	return cli, errors.Wrapf(err, "Unable to build a docker client for the %s context", c.Name)
+}
+
+func (e Endpoint) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: e.SkipTLSVerify,
+	}
+
+	if host, err := url.Parse(e.Host); err == nil {
+		config.ServerName = host.Hostname()
+	}
+
+	caPath := filepath.Join(e.TLSDir, "ca.pem")
+	if ca, err := ioutil.ReadFile(caPath); err == nil {
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.Errorf("Unable to load the CA certificate at %s", caPath)
+		}
+	}
+
+	certPath := filepath.Join(e.TLSDir, "cert.pem")
+	keyPath := filepath.Join(e.TLSDir, "key.pem")
+	if _, err := os.Stat(certPath); err == nil {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to load the client certificate at %s", certPath)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
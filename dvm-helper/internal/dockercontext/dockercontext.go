@@ -0,0 +1,182 @@
+// Package dockercontext reads the contexts saved by the docker client
+// and builds API clients for their endpoints.
+package dockercontext
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+)
+
+// DefaultContextName is the implicit context which is configured by the DOCKER_* environment variables.
+const DefaultContextName = "default"
+
+// Context is a named Docker endpoint.
+type Context struct {
+	Name        string
+	Description string
+	Endpoint    Endpoint
+}
+
+// Endpoint describes how to connect to a Docker daemon.
+type Endpoint struct {
+	// Host is the daemon address, e.g. unix:///var/run/docker.sock, tcp://host:2376 or ssh://user@host
+	Host string
+
+	// SkipTLSVerify disables verification of the daemon's certificate.
+	SkipTLSVerify bool
+
+	// TLSDir is the directory containing ca.pem, cert.pem and key.pem. Empty when TLS is not used.
+	TLSDir string
+}
+
+// Store reads contexts from a docker configuration directory, e.g. ~/.docker.
+type Store struct {
+	configDir string
+}
+
+type contextMetadata struct {
+	Name     string
+	Metadata struct {
+		Description string
+	}
+	Endpoints map[string]struct {
+		Host          string
+		SkipTLSVerify bool
+	}
+}
+
+type configFile struct {
+	CurrentContext string `json:"currentContext"`
+}
+
+// NewStore creates a context store for the specified docker configuration directory.
+func NewStore(configDir string) Store {
+	return Store{configDir: configDir}
+}
+
+// Current returns the name of the active context, following the same precedence as the docker client:
+// DOCKER_CONTEXT, then DOCKER_HOST, then the currentContext saved in config.json.
+func (s Store) Current() string {
+	if name := os.Getenv("DOCKER_CONTEXT"); name != "" {
+		return name
+	}
+
+	if os.Getenv("DOCKER_HOST") != "" {
+		return DefaultContextName
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(s.configDir, "config.json"))
+	if err != nil {
+		return DefaultContextName
+	}
+
+	var config configFile
+	if err := json.Unmarshal(contents, &config); err != nil || config.CurrentContext == "" {
+		return DefaultContextName
+	}
+	return config.CurrentContext
+}
+
+// Load reads the named context. The default context is built from the environment.
+func (s Store) Load(name string) (Context, error) {
+	if name == DefaultContextName {
+		return defaultContext(), nil
+	}
+
+	metaPath := filepath.Join(s.configDir, "contexts", "meta", contextDir(name), "meta.json")
+	contents, err := ioutil.ReadFile(metaPath)
+	if os.IsNotExist(err) {
+		return Context{}, errors.Errorf("The docker context %s does not exist", name)
+	}
+	if err != nil {
+		return Context{}, errors.Wrapf(err, "Unable to read the docker context %s at %s", name, metaPath)
+	}
+
+	return s.parse(contents, metaPath)
+}
+
+// List returns the default context followed by every saved context, sorted by name.
+func (s Store) List() ([]Context, error) {
+	metaPaths, err := filepath.Glob(filepath.Join(s.configDir, "contexts", "meta", "*", "meta.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to list docker contexts")
+	}
+
+	var results []Context
+	for _, metaPath := range metaPaths {
+		contents, err := ioutil.ReadFile(metaPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to read the docker context at %s", metaPath)
+		}
+
+		c, err := s.parse(contents, metaPath)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, c)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+
+	return append([]Context{defaultContext()}, results...), nil
+}
+
+func (s Store) parse(contents []byte, metaPath string) (Context, error) {
+	var meta contextMetadata
+	if err := json.Unmarshal(contents, &meta); err != nil {
+		return Context{}, errors.Wrapf(err, "Unable to parse the docker context at %s", metaPath)
+	}
+
+	docker, ok := meta.Endpoints["docker"]
+	if !ok || docker.Host == "" {
+		return Context{}, errors.Errorf("The docker context %s does not have a docker endpoint", meta.Name)
+	}
+
+	c := Context{
+		Name:        meta.Name,
+		Description: meta.Metadata.Description,
+		Endpoint: Endpoint{
+			Host:          docker.Host,
+			SkipTLSVerify: docker.SkipTLSVerify,
+		},
+	}
+
+	tlsDir := filepath.Join(s.configDir, "contexts", "tls", contextDir(meta.Name), "docker")
+	if _, err := os.Stat(tlsDir); err == nil {
+		c.Endpoint.TLSDir = tlsDir
+	}
+
+	return c, nil
+}
+
+// defaultContext mirrors how the docker client configures itself from DOCKER_HOST, DOCKER_TLS_VERIFY and DOCKER_CERT_PATH.
+func defaultContext() Context {
+	c := Context{
+		Name:        DefaultContextName,
+		Description: "Current DOCKER_HOST based configuration",
+		Endpoint: Endpoint{
+			Host:          os.Getenv("DOCKER_HOST"),
+			TLSDir:        os.Getenv("DOCKER_CERT_PATH"),
+			SkipTLSVerify: os.Getenv("DOCKER_TLS_VERIFY") == "",
+		},
+	}
+	if c.Endpoint.Host == "" {
+		c.Endpoint.Host = client.DefaultDockerHost
+	}
+	return c
+}
+
+// contextDir is the directory name in which the docker client saves a context.
+func contextDir(name string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(name)))
+}
@@ -0,0 +1,124 @@
+package dockercontext
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestStore creates a docker config directory with contexts, which is removed when the test completes
+func createTestStore(t *testing.T) Store {
+	configDir, err := ioutil.TempDir("", "dvmtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(configDir) })
+
+	writeContext := func(name string, meta string) {
+		metaDir := filepath.Join(configDir, "contexts", "meta", contextDir(name))
+		os.MkdirAll(metaDir, 0755)
+		ioutil.WriteFile(filepath.Join(metaDir, "meta.json"), []byte(meta), 0644)
+	}
+	writeContext("remote", `{"Name":"remote","Metadata":{"Description":"tls daemon"},"Endpoints":{"docker":{"Host":"tcp://remote:2376","SkipTLSVerify":false}}}`)
+	writeContext("build", `{"Name":"build","Metadata":{},"Endpoints":{"docker":{"Host":"ssh://builder@build-box","SkipTLSVerify":false}}}`)
+	os.MkdirAll(filepath.Join(configDir, "contexts", "tls", contextDir("remote"), "docker"), 0755)
+
+	ioutil.WriteFile(filepath.Join(configDir, "config.json"), []byte(`{"currentContext":"remote"}`), 0644)
+
+	return NewStore(configDir)
+}
+
+func TestStore_Load(t *testing.T) {
+	s := createTestStore(t)
+
+	c, err := s.Load("remote")
+	if err != nil {
+		t.Fatalf("%#v", err)
+	}
+	assert.Equal(t, "remote", c.Name)
+	assert.Equal(t, "tls daemon", c.Description)
+	assert.Equal(t, "tcp://remote:2376", c.Endpoint.Host)
+	assert.NotEmpty(t, c.Endpoint.TLSDir, "The TLS directory should be set when the context has TLS material")
+
+	c, err = s.Load("build")
+	if err != nil {
+		t.Fatalf("%#v", err)
+	}
+	assert.Equal(t, "ssh://builder@build-box", c.Endpoint.Host)
+	assert.Empty(t, c.Endpoint.TLSDir, "The TLS directory should be empty when the context has no TLS material")
+
+	_, err = s.Load("missing")
+	assert.Error(t, err, "Loading a missing context should fail")
+}
+
+func TestStore_List(t *testing.T) {
+	s := createTestStore(t)
+
+	contexts, err := s.List()
+	if err != nil {
+		t.Fatalf("%#v", err)
+	}
+
+	var names []string
+	for _, c := range contexts {
+		names = append(names, c.Name)
+	}
+	assert.Equal(t, []string{DefaultContextName, "build", "remote"}, names)
+}
+
+func TestStore_Current(t *testing.T) {
+	s := createTestStore(t)
+	t.Setenv("DOCKER_HOST", "")
+	t.Setenv("DOCKER_CONTEXT", "")
+
+	assert.Equal(t, "remote", s.Current(), "The current context should be read from config.json")
+
+	t.Setenv("DOCKER_HOST", "tcp://localhost:2375")
+	assert.Equal(t, DefaultContextName, s.Current(), "DOCKER_HOST should take precedence over config.json")
+
+	t.Setenv("DOCKER_CONTEXT", "build")
+	assert.Equal(t, "build", s.Current(), "DOCKER_CONTEXT should take precedence over DOCKER_HOST")
+}
+
+func TestNewSSHTransport(t *testing.T) {
+	_, err := newSSHTransport("ssh://builder@build-box:2222")
+	assert.NoError(t, err)
+
+	_, err = newSSHTransport("ssh://")
+	assert.Error(t, err, "An ssh host without a hostname should be rejected")
+}
+
+func TestCommandConn_Deadline(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The test relies on cat")
+	}
+
+	conn, err := dialCommand("cat")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+
+	// Nothing else is written, so the read only returns at the deadline
+	conn.SetDeadline(time.Now().Add(50 * time.Millisecond))
+	start := time.Now()
+	_, err = conn.Read(buf)
+	if assert.Error(t, err) {
+		netErr, ok := err.(net.Error)
+		assert.True(t, ok && netErr.Timeout(), "The read should time out, got %s", err)
+	}
+	assert.True(t, time.Since(start) < 5*time.Second, "The read should not block past the deadline")
+}
@@ -0,0 +1,176 @@
+package dockercontext
+
+import (
+	stderrors "errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// newSSHTransport tunnels requests to the remote daemon through `docker system dial-stdio`,
+// the same way that the docker client connects to ssh:// hosts.
+func newSSHTransport(host string) (*http.Transport, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to parse the ssh host %s", host)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.Errorf("The ssh host %s is missing a hostname", host)
+	}
+
+	var args []string
+	if u.User != nil {
+		args = append(args, "-l", u.User.Username())
+	}
+	if u.Port() != "" {
+		args = append(args, "-p", u.Port())
+	}
+	args = append(args, "--", u.Hostname(), "docker", "system", "dial-stdio")
+
+	return &http.Transport{
+		DisableKeepAlives: true,
+		Dial: func(_, _ string) (net.Conn, error) {
+			return dialCommand("ssh", args...)
+		},
+	}, nil
+}
+
+// commandConn is a net.Conn backed by the stdin and stdout of a process.
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  *os.File
+	stdout *os.File
+
+	closeOnce  sync.Once
+	timerMu    sync.Mutex
+	readTimer  *time.Timer
+	writeTimer *time.Timer
+}
+
+func dialCommand(name string, args ...string) (net.Conn, error) {
+	cmd := exec.Command(name, args...)
+
+	// Use pipes that we own, rather than cmd.StdinPipe, so that they support deadlines
+	stdinReader, stdin, err := os.Pipe()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to connect to %s", name)
+	}
+	stdout, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		stdinReader.Close()
+		stdin.Close()
+		return nil, errors.Wrapf(err, "Unable to connect to %s", name)
+	}
+	cmd.Stdin = stdinReader
+	cmd.Stdout = stdoutWriter
+
+	err = cmd.Start()
+	// The process has its own copies of these ends of the pipes
+	stdinReader.Close()
+	stdoutWriter.Close()
+	if err != nil {
+		stdin.Close()
+		stdout.Close()
+		return nil, errors.Wrapf(err, "Unable to start %s", name)
+	}
+	return &commandConn{cmd: cmd, stdin: stdin, stdout: stdout}, nil
+}
+
+func (c *commandConn) Read(p []byte) (int, error) {
+	n, err := c.stdout.Read(p)
+	return n, unwrapTimeout(err)
+}
+
+func (c *commandConn) Write(p []byte) (int, error) {
+	n, err := c.stdin.Write(p)
+	return n, unwrapTimeout(err)
+}
+
+// Pipes report a missed deadline as a *os.PathError, which isn't a net.Error like net.Conn callers expect
+func unwrapTimeout(err error) error {
+	if stderrors.Is(err, os.ErrDeadlineExceeded) {
+		return os.ErrDeadlineExceeded
+	}
+	return err
+}
+
+func (c *commandConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.timerMu.Lock()
+		stopTimer(c.readTimer)
+		stopTimer(c.writeTimer)
+		c.timerMu.Unlock()
+
+		c.stdin.Close()
+		c.stdout.Close()
+		if c.cmd.Process != nil {
+			c.cmd.Process.Kill()
+		}
+		c.cmd.Wait()
+	})
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr {
+	return dummyAddr{}
+}
+
+func (c *commandConn) RemoteAddr() net.Addr {
+	return dummyAddr{}
+}
+
+func (c *commandConn) SetDeadline(t time.Time) error {
+	c.SetReadDeadline(t)
+	c.SetWriteDeadline(t)
+	return nil
+}
+
+func (c *commandConn) SetReadDeadline(t time.Time) error {
+	if err := c.stdout.SetReadDeadline(t); err != nil {
+		c.closeAt(&c.readTimer, t)
+	}
+	return nil
+}
+
+func (c *commandConn) SetWriteDeadline(t time.Time) error {
+	if err := c.stdin.SetWriteDeadline(t); err != nil {
+		c.closeAt(&c.writeTimer, t)
+	}
+	return nil
+}
+
+// closeAt kills the process at the deadline, for pipes which don't support deadlines, e.g. on Windows.
+// A blocked read or write can't be resumed afterwards, so the connection is unusable once the deadline passes.
+func (c *commandConn) closeAt(timer **time.Timer, t time.Time) {
+	c.timerMu.Lock()
+	defer c.timerMu.Unlock()
+
+	stopTimer(*timer)
+	*timer = nil
+	if t.IsZero() {
+		return
+	}
+	*timer = time.AfterFunc(time.Until(t), func() { c.Close() })
+}
+
+func stopTimer(timer *time.Timer) {
+	if timer != nil {
+		timer.Stop()
+	}
+}
+
+type dummyAddr struct{}
+
+func (dummyAddr) Network() string {
+	return "ssh"
+}
+
+func (dummyAddr) String() string {
+	return "ssh"
+}
@@ -0,0 +1,403 @@
+package downloader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+)
+
+// DefaultMaxExtractSize is the largest total size of the entries in an archive that will be extracted.
+const DefaultMaxExtractSize int64 = 1 << 30
+
+// UnsafeEntryError is returned when an archive contains an entry that could write outside of the extraction directory,
+// or that is not a regular file, directory or link.
+type UnsafeEntryError struct {
+	Archive string
+	Entry   string
+	Reason  string
+}
+
+func (e UnsafeEntryError) Error() string {
+	return fmt.Sprintf("Refusing to extract %s, the entry %s %s", e.Archive, e.Entry, e.Reason)
+}
+
+// SizeLimitError is returned when the entries in an archive are larger than the extraction limit.
+type SizeLimitError struct {
+	Archive string
+	Limit   int64
+}
+
+func (e SizeLimitError) Error() string {
+	return fmt.Sprintf("Refusing to extract %s, it expands to more than %d bytes", e.Archive, e.Limit)
+}
+
+// stagedFile is an extracted file waiting to be moved to its destination
+type stagedFile struct {
+	ArchivedFile
+	tmpPath string
+}
+
+// extractStream saves the requested files from a gzipped tar or zip archive.
+// Gzipped tars are extracted as they are read, zips are first saved to the temp directory
+// because their index is at the end of the file.
+// The archive is always read to the end, so that it can be hashed by the caller.
+func (d Client) extractStream(archive io.Reader, archiveName string, files []ArchivedFile) ([]stagedFile, error) {
+	r := bufio.NewReader(archive)
+	header, _ := r.Peek(len(zipMagic))
+
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		staged, err := d.extractTarGz(r, archiveName, files)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = io.Copy(ioutil.Discard, r); err != nil {
+			removeStagedFiles(staged)
+			return nil, errors.Wrapf(err, "Unable to read %s", archiveName)
+		}
+		return staged, nil
+	case bytes.HasPrefix(header, zipMagic):
+		tmpPath := filepath.Join(d.tmp, archiveName)
+		if err := d.ensureParentDirectoryExists(tmpPath); err != nil {
+			return nil, err
+		}
+		tmpFile, err := os.Create(tmpPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to create %s", tmpPath)
+		}
+		_, err = io.Copy(tmpFile, r)
+		tmpFile.Close()
+		defer d.removeTempFile(tmpPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to write to %s", tmpPath)
+		}
+		return d.extractZip(tmpPath, archiveName, files)
+	default:
+		return nil, errors.Errorf("Unable to extract %s, it is not a gzip or zip archive", archiveName)
+	}
+}
+
+// extractFile saves the requested files from a local gzipped tar or zip archive.
+func (d Client) extractFile(archivePath string, files []ArchivedFile) ([]stagedFile, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to open %s", archivePath)
+	}
+	defer f.Close()
+
+	header := make([]byte, len(zipMagic))
+	n, _ := io.ReadFull(f, header)
+	if bytes.HasPrefix(header[:n], zipMagic) {
+		return d.extractZip(archivePath, filepath.Base(archivePath), files)
+	}
+
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return nil, errors.Wrapf(err, "Unable to read %s", archivePath)
+	}
+	return d.extractStream(f, filepath.Base(archivePath), files)
+}
+
+func (d Client) extractTarGz(r io.Reader, archiveName string, files []ArchivedFile) ([]stagedFile, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to decompress %s", archiveName)
+	}
+	defer gz.Close()
+
+	var staged []stagedFile
+	var totalSize int64
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			removeStagedFiles(staged)
+			return nil, errors.Wrapf(err, "Unable to read %s", archiveName)
+		}
+
+		totalSize += header.Size
+		err = d.checkTarEntry(header, archiveName, totalSize)
+		if err != nil {
+			removeStagedFiles(staged)
+			return nil, err
+		}
+
+		file, ok := findArchivedFile(files, header.Name)
+		if !ok {
+			continue
+		}
+		if header.Typeflag != tar.TypeReg {
+			removeStagedFiles(staged)
+			return nil, UnsafeEntryError{Archive: archiveName, Entry: header.Name, Reason: "is not a regular file"}
+		}
+
+		d.log.Printf("Extracting %s from %s\n", header.Name, archiveName)
+		tmpPath, err := d.stageFile(tr, file.DestPath)
+		if err != nil {
+			removeStagedFiles(staged)
+			return nil, errors.Wrapf(err, "Unable to extract %s from %s", header.Name, archiveName)
+		}
+		staged = append(staged, stagedFile{ArchivedFile: file, tmpPath: tmpPath})
+	}
+
+	return checkStagedFiles(staged, files, archiveName)
+}
+
+func (d Client) extractZip(archivePath string, archiveName string, files []ArchivedFile) ([]stagedFile, error) {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read %s", archiveName)
+	}
+	defer zr.Close()
+
+	var totalSize int64
+	for _, entry := range zr.File {
+		totalSize += int64(entry.UncompressedSize64)
+		if err = d.checkZipEntry(entry, archiveName, totalSize); err != nil {
+			return nil, err
+		}
+	}
+
+	var staged []stagedFile
+	for _, entry := range zr.File {
+		file, ok := findArchivedFile(files, entry.Name)
+		if !ok {
+			continue
+		}
+		if !entry.Mode().IsRegular() {
+			removeStagedFiles(staged)
+			return nil, UnsafeEntryError{Archive: archiveName, Entry: entry.Name, Reason: "is not a regular file"}
+		}
+
+		d.log.Printf("Extracting %s from %s\n", entry.Name, archiveName)
+		tmpPath, err := d.stageZipEntry(entry, file.DestPath)
+		if err != nil {
+			removeStagedFiles(staged)
+			return nil, errors.Wrapf(err, "Unable to extract %s from %s", entry.Name, archiveName)
+		}
+		staged = append(staged, stagedFile{ArchivedFile: file, tmpPath: tmpPath})
+	}
+
+	return checkStagedFiles(staged, files, archiveName)
+}
+
+// Reject tar entries that are devices, or whose path or link target is outside of the archive
+func (d Client) checkTarEntry(header *tar.Header, archiveName string, totalSize int64) error {
+	if totalSize > d.maxExtractSize || header.Size < 0 {
+		return SizeLimitError{Archive: archiveName, Limit: d.maxExtractSize}
+	}
+
+	if reason := checkEntryPath(header.Name); reason != "" {
+		return UnsafeEntryError{Archive: archiveName, Entry: header.Name, Reason: reason}
+	}
+
+	switch header.Typeflag {
+	case tar.TypeReg, tar.TypeDir, tar.TypeXGlobalHeader:
+		return nil
+	case tar.TypeSymlink:
+		if reason := checkLinkTarget(header.Name, header.Linkname); reason != "" {
+			return UnsafeEntryError{Archive: archiveName, Entry: header.Name, Reason: reason}
+		}
+		return nil
+	case tar.TypeLink:
+		// Hard links are relative to the root of the archive
+		if reason := checkEntryPath(header.Linkname); reason != "" {
+			return UnsafeEntryError{Archive: archiveName, Entry: header.Name, Reason: "links to " + header.Linkname + " which " + reason}
+		}
+		return nil
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		return UnsafeEntryError{Archive: archiveName, Entry: header.Name, Reason: "is a device file"}
+	default:
+		return UnsafeEntryError{Archive: archiveName, Entry: header.Name, Reason: fmt.Sprintf("has an unsupported type %q", header.Typeflag)}
+	}
+}
+
+// Reject zip entries that are devices, or whose path or link target is outside of the archive
+func (d Client) checkZipEntry(entry *zip.File, archiveName string, totalSize int64) error {
+	if totalSize > d.maxExtractSize || totalSize < 0 {
+		return SizeLimitError{Archive: archiveName, Limit: d.maxExtractSize}
+	}
+
+	if reason := checkEntryPath(entry.Name); reason != "" {
+		return UnsafeEntryError{Archive: archiveName, Entry: entry.Name, Reason: reason}
+	}
+
+	mode := entry.Mode()
+	switch {
+	case mode.IsRegular(), mode.IsDir():
+		return nil
+	case mode&os.ModeSymlink != 0:
+		// The target of a symlink is stored as the contents of the entry
+		target, err := readZipLink(entry)
+		if err != nil {
+			return errors.Wrapf(err, "Unable to read %s from %s", entry.Name, archiveName)
+		}
+		if reason := checkLinkTarget(entry.Name, target); reason != "" {
+			return UnsafeEntryError{Archive: archiveName, Entry: entry.Name, Reason: reason}
+		}
+		return nil
+	case mode&(os.ModeDevice|os.ModeCharDevice|os.ModeNamedPipe|os.ModeSocket) != 0:
+		return UnsafeEntryError{Archive: archiveName, Entry: entry.Name, Reason: "is a device file"}
+	default:
+		return UnsafeEntryError{Archive: archiveName, Entry: entry.Name, Reason: fmt.Sprintf("has an unsupported mode %s", mode)}
+	}
+}
+
+func readZipLink(entry *zip.File) (string, error) {
+	r, err := entry.Open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	target, err := ioutil.ReadAll(io.LimitReader(r, 4096))
+	return string(target), err
+}
+
+// Check that an entry path stays within the archive, returning why it is unsafe
+func checkEntryPath(name string) string {
+	if isAbsolutePath(name) {
+		return "is an absolute path"
+	}
+	for _, part := range strings.Split(toSlash(name), "/") {
+		if part == ".." {
+			return "is outside of the archive"
+		}
+	}
+	return ""
+}
+
+// Check that a symlink points within the archive, returning why it is unsafe
+func checkLinkTarget(name string, target string) string {
+	if isAbsolutePath(target) {
+		return "links to the absolute path " + target
+	}
+
+	resolved := path.Join(path.Dir(toSlash(name)), toSlash(target))
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return "links to " + target + " which is outside of the archive"
+	}
+	return ""
+}
+
+// Archives may be extracted on any OS, so check for both unix and windows absolute paths
+func isAbsolutePath(name string) bool {
+	name = toSlash(name)
+	return strings.HasPrefix(name, "/") || (len(name) >= 2 && name[1] == ':')
+}
+
+func toSlash(name string) string {
+	return strings.Replace(name, "\\", "/", -1)
+}
+
+func (d Client) stageZipEntry(entry *zip.File, destPath string) (string, error) {
+	r, err := entry.Open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	return d.stageFile(r, destPath)
+}
+
+// Write a file next to its destination, so that it can be renamed into place once the whole archive is verified
+func (d Client) stageFile(r io.Reader, destPath string) (string, error) {
+	if err := d.ensureParentDirectoryExists(destPath); err != nil {
+		return "", err
+	}
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(destPath), "."+filepath.Base(destPath)+".")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(tmpFile, r)
+	closeErr := tmpFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpFile.Name(), 0755)
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return "", err
+	}
+
+	return tmpFile.Name(), nil
+}
+
+// Move the staged files to their destinations
+func (d Client) commitStagedFiles(staged []stagedFile) ([]ArchivedFile, error) {
+	var results []ArchivedFile
+	for i, file := range staged {
+		err := os.Rename(file.tmpPath, file.DestPath)
+		if err != nil {
+			removeStagedFiles(staged[i:])
+			return results, errors.Wrapf(err, "Unable to copy %s to %s", file.tmpPath, file.DestPath)
+		}
+		results = append(results, file.ArchivedFile)
+	}
+	return results, nil
+}
+
+func (d Client) removeTempFile(tmpPath string) {
+	if err := os.Remove(tmpPath); err != nil && !os.IsNotExist(err) {
+		d.log.Println(errors.Wrapf(err, "Unable to remove temporary file %s", tmpPath))
+	}
+}
+
+func removeStagedFiles(staged []stagedFile) {
+	for _, file := range staged {
+		os.Remove(file.tmpPath)
+	}
+}
+
+// Find the requested file for an archive entry, ignoring differences such as a leading ./
+func findArchivedFile(files []ArchivedFile, entryName string) (ArchivedFile, bool) {
+	entryName = path.Clean(strings.TrimPrefix(entryName, "./"))
+	for _, file := range files {
+		if path.Clean(filepath.ToSlash(file.Path)) == entryName {
+			return file, true
+		}
+	}
+	return ArchivedFile{}, false
+}
+
+// Check that every required file was found in the archive,
+// and order the staged files as they were requested rather than as they appear in the archive
+func checkStagedFiles(staged []stagedFile, files []ArchivedFile, archiveName string) ([]stagedFile, error) {
+	var results []stagedFile
+	for _, file := range files {
+		found := false
+		for _, s := range staged {
+			if s.Path == file.Path {
+				results = append(results, s)
+				found = true
+			}
+		}
+		if !found && !file.Optional {
+			removeStagedFiles(staged)
+			return nil, errors.Errorf("Unable to find %s in %s", file.Path, archiveName)
+		}
+	}
+	return results, nil
+}
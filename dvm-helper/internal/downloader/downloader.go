@@ -1,32 +1,50 @@
 package downloader
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
-	"strings"
+	"time"
 
 	"github.com/howtowhale/dvm/dvm-helper/checksum"
 	"github.com/howtowhale/dvm/dvm-helper/internal/config"
-	"github.com/pivotal-golang/archiver/extractor"
 	"github.com/pkg/errors"
 )
 
 // Client is capable of downloading archived and checksumed files.
 type Client struct {
-	log *log.Logger
-	tmp string
+	log            *log.Logger
+	tmp            string
+	maxExtractSize int64
+	http           *http.Client
+	readTimeout    time.Duration
+	retries        int
+	retryDelay     time.Duration
 }
 
 // New creates a downloader client.
-// l - optional logger for debug output
+// opts - the dvm home directory, logger, HTTP client, read timeout and number of retries
 func New(opts config.DvmOptions) Client {
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
 	return Client{
-		log: opts.Logger,
-		tmp: filepath.Join(opts.DvmDir, ".tmp"),
+		log:            opts.Logger,
+		tmp:            filepath.Join(opts.DvmDir, ".tmp"),
+		maxExtractSize: DefaultMaxExtractSize,
+		http:           httpClient,
+		readTimeout:    opts.ReadTimeout,
+		retries:        opts.Retries,
+		retryDelay:     defaultRetryDelay,
 	}
 }
 
@@ -36,57 +54,83 @@ func (d Client) ensureParentDirectoryExists(path string) error {
 }
 
 // DownloadFile saves a file without any additional processing.
-func (d Client) DownloadFile(url string, destPath string) error {
+// The file is only moved to destPath once it is completely downloaded.
+func (d Client) DownloadFile(ctx context.Context, url string, destPath string) error {
 	err := d.ensureParentDirectoryExists(destPath)
 	if err != nil {
 		return err
 	}
 
-	destFile, err := os.Create(destPath)
-	if err != nil {
-		return errors.Wrapf(err, "Unable to create %s", destPath)
-	}
-	defer destFile.Close()
-	os.Chmod(destPath, 0755)
-
 	d.log.Printf("Downloading %s to %s\n", url, destPath)
 
-	response, err := http.Get(url)
-	if err != nil {
-		return errors.Wrapf(err, "Unable to download %s", url)
-	}
+	return d.withRetries(ctx, url, func() error {
+		response, err := d.open(ctx, url)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
 
-	if response.StatusCode != 200 {
-		return errors.Wrapf(err, "Unable to download %s (Status %d)", url, response.StatusCode)
-	}
-	defer response.Body.Close()
+		tmpPath, err := d.stageFile(response.Body, destPath)
+		if err != nil {
+			return errors.Wrapf(err, "Unable to download %s", url)
+		}
 
-	_, err = io.Copy(destFile, response.Body)
-	return errors.Wrapf(err, "Unable to write to %s", destPath)
+		err = os.Rename(tmpPath, destPath)
+		if err != nil {
+			os.Remove(tmpPath)
+			return errors.Wrapf(err, "Unable to copy %s to %s", tmpPath, destPath)
+		}
+		return nil
+	})
+}
+
+// Exists checks if a file can be downloaded, without downloading it.
+// Client errors, such as 404 or the 403 returned by S3 for missing files, mean that the file does not exist.
+func (d Client) Exists(ctx context.Context, url string) (bool, error) {
+	var exists bool
+	err := d.withRetries(ctx, url, func() error {
+		response, err := d.send(ctx, http.MethodHead, url)
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+
+		switch {
+		case response.StatusCode < 400:
+			exists = true
+		case response.StatusCode < 500 && response.StatusCode != http.StatusTooManyRequests && response.StatusCode != http.StatusUnauthorized:
+			exists = false
+		default:
+			return HTTPError{URL: url, StatusCode: response.StatusCode}
+		}
+		return nil
+	})
+	return exists, err
 }
 
 // Download file saves a file after verifying the checksum found at url + ".sh256".
-func (d Client) DownloadFileWithChecksum(url string, destPath string) error {
+func (d Client) DownloadFileWithChecksum(ctx context.Context, url string, destPath string) error {
 	fileName := filepath.Base(destPath)
 	tmpPath := filepath.Join(d.tmp, fileName)
-	err := d.DownloadFile(url, tmpPath)
+	err := d.DownloadFile(ctx, url, tmpPath)
 	if err != nil {
 		return err
 	}
+	if destPath != tmpPath {
+		defer d.removeTempFile(tmpPath)
+	}
 
 	checksumURL := url + ".sha256"
 	checksumPath := filepath.Join(d.tmp, fileName+".sha256")
-	err = d.DownloadFile(checksumURL, checksumPath)
+	err = d.DownloadFile(ctx, checksumURL, checksumPath)
 	if err != nil {
 		return err
 	}
+	defer d.removeTempFile(checksumPath)
 
-	isValid, err := checksum.CompareChecksum(tmpPath, checksumPath)
+	err = d.VerifyChecksum(tmpPath, checksumPath)
 	if err != nil {
-		return errors.Wrapf(err, "Unable to calculate checksum of %s", tmpPath)
-	}
-	if !isValid {
-		return errors.Wrapf(err, "The checksum of %s failed to match %s", tmpPath, checksumPath)
+		return err
 	}
 
 	// Copy to final location, if different
@@ -102,73 +146,138 @@ func (d Client) DownloadFileWithChecksum(url string, destPath string) error {
 		}
 	}
 
-	// Cleanup temp files
-	if err = os.Remove(checksumPath); err != nil {
-		d.log.Println(errors.Wrapf(err, "Unable to remove temporary file %s", checksumPath))
-	}
-
 	return nil
 }
 
+// ArchivedFile is a file to save from an archive.
+type ArchivedFile struct {
+	// Path is the relative path to the file in the archive
+	Path string
+
+	// DestPath is the location where the file should be saved
+	DestPath string
+
+	// Optional files are skipped when they are not in the archive
+	Optional bool
+}
+
 // DownloadArchivedFile downloads the archive, decompresses it and saves the specified file to the destination path.
-// url - URL of the archived file, e.g. a gzip, zip or tar file
+// url - URL of the archived file, e.g. a gzip or zip file
 // archivedFile - relative path to the desired file in the archive
 // destPath - location where the archivedFile should be saved
-func (d Client) DownloadArchivedFile(url string, archivedFile string, destPath string) error {
-	archiveName := path.Base(url)
-	tmpPath := filepath.Join(d.tmp, archiveName)
-
-	err := d.DownloadFile(url, tmpPath)
-	if err != nil {
-		return err
-	}
-
-	return d.extractArchive(tmpPath, archiveName, archivedFile, destPath)
+func (d Client) DownloadArchivedFile(ctx context.Context, url string, archivedFile string, destPath string) error {
+	_, err := d.DownloadArchivedFiles(ctx, url, []ArchivedFile{{Path: archivedFile, DestPath: destPath}})
+	return err
 }
 
 // DownloadArchivedFileWithChecksum first verifies the checksum found at url + ".sh256",
 // decompresses the archive, and then saves the specified file to the destination path.
-// url - URL of the archived file, e.g. a gzip, zip or tar file
+// url - URL of the archived file, e.g. a gzip or zip file
 // archivedFile - relative path to the desired file in the archive
 // destPath - location where the archivedFile should be saved
-func (d Client) DownloadArchivedFileWithChecksum(url string, archivedFile string, destPath string) error {
-	archiveName := path.Base(url)
-	tmpPath := filepath.Join(d.tmp, archiveName)
+func (d Client) DownloadArchivedFileWithChecksum(ctx context.Context, url string, archivedFile string, destPath string) error {
+	_, err := d.DownloadArchivedFilesWithChecksum(ctx, url, []ArchivedFile{{Path: archivedFile, DestPath: destPath}})
+	return err
+}
+
+// DownloadArchivedFiles streams the archive, saving only the specified files,
+// and returns the files which were found in the archive.
+// url - URL of the archived file, e.g. a gzip or zip file
+// files - the files to save from the archive
+func (d Client) DownloadArchivedFiles(ctx context.Context, url string, files []ArchivedFile) ([]ArchivedFile, error) {
+	return d.downloadArchive(ctx, url, "", files)
+}
 
-	err := d.DownloadFileWithChecksum(url, tmpPath)
+// DownloadArchivedFilesWithChecksum streams the archive, saving only the specified files once the archive
+// matches the checksum found at url + ".sh256", and returns the files which were found in the archive.
+// url - URL of the archived file, e.g. a gzip or zip file
+// files - the files to save from the archive
+func (d Client) DownloadArchivedFilesWithChecksum(ctx context.Context, url string, files []ArchivedFile) ([]ArchivedFile, error) {
+	return d.downloadArchive(ctx, url, url+".sha256", files)
+}
+
+// ExtractArchivedFile decompresses a local archive and saves the specified file to the destination path.
+// archivePath - location of a gzip or zip file
+// archivedFile - relative path to the desired file in the archive
+// destPath - location where the archivedFile should be saved
+func (d Client) ExtractArchivedFile(archivePath string, archivedFile string, destPath string) error {
+	staged, err := d.extractFile(archivePath, []ArchivedFile{{Path: archivedFile, DestPath: destPath}})
 	if err != nil {
 		return err
 	}
 
-	return d.extractArchive(tmpPath, archiveName, archivedFile, destPath)
+	_, err = d.commitStagedFiles(staged)
+	return err
 }
 
-func (d Client) extractArchive(tmpPath string, archiveName string, archivedFile string, destPath string) error {
-	// Extract the archive
-	archivePath := filepath.Join(d.tmp, strings.TrimSuffix(archiveName, filepath.Ext(archiveName)))
-	x := extractor.NewDetectable()
-	x.Extract(tmpPath, archivePath)
+// VerifyChecksum validates the SHA256 checksum of a file against its checksum file.
+func (d Client) VerifyChecksum(filePath string, checksumPath string) error {
+	isValid, err := checksum.CompareChecksum(filePath, checksumPath)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to calculate checksum of %s", filePath)
+	}
+	if !isValid {
+		return errors.Errorf("The checksum of %s failed to match %s", filePath, checksumPath)
+	}
 
-	// Copy the archived file to the final destination
-	archivedFilePath := filepath.Join(archivePath, archivedFile)
+	d.log.Printf("Verified the checksum of %s\n", filePath)
+	return nil
+}
 
-	err := d.ensureParentDirectoryExists(destPath)
-	if err != nil {
-		return err
+func (d Client) downloadArchive(ctx context.Context, url string, checksumURL string, files []ArchivedFile) ([]ArchivedFile, error) {
+	var knownChecksum string
+	if checksumURL != "" {
+		contents, err := d.get(ctx, checksumURL)
+		if err != nil {
+			return nil, err
+		}
+		knownChecksum = checksum.ParseChecksum(string(contents))
 	}
 
-	err = os.Rename(archivedFilePath, destPath)
+	d.log.Printf("Downloading %s\n", url)
+	var staged []stagedFile
+	err := d.withRetries(ctx, url, func() error {
+		response, err := d.open(ctx, url)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+
+		hash := sha256.New()
+		staged, err = d.extractStream(io.TeeReader(response.Body, hash), path.Base(url), files)
+		if err != nil {
+			return err
+		}
+
+		if knownChecksum != "" {
+			if hex.EncodeToString(hash.Sum(nil)) != knownChecksum {
+				removeStagedFiles(staged)
+				return errors.Errorf("The checksum of %s failed to match %s", url, checksumURL)
+			}
+			d.log.Printf("Verified the checksum of %s\n", url)
+		}
+		return nil
+	})
 	if err != nil {
-		return errors.Wrapf(err, "Unable to copy %s to %s", archivedFilePath, destPath)
+		return nil, err
 	}
 
-	// Cleanup temp files
-	if err = os.Remove(tmpPath); err != nil {
-		d.log.Println(errors.Wrapf(err, "Unable to remove temporary file %s", tmpPath))
-	}
-	if err = os.RemoveAll(archivePath); err != nil {
-		d.log.Println(errors.Wrapf(err, "Unable to remove temporary directory %s", archivePath))
-	}
+	return d.commitStagedFiles(staged)
+}
 
-	return nil
+// Read a small file, such as a checksum, into memory
+func (d Client) get(ctx context.Context, url string) ([]byte, error) {
+	d.log.Printf("Downloading %s\n", url)
+	var contents []byte
+	err := d.withRetries(ctx, url, func() error {
+		response, err := d.open(ctx, url)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+
+		contents, err = ioutil.ReadAll(response.Body)
+		return errors.Wrapf(err, "Unable to download %s", url)
+	})
+	return contents, err
 }
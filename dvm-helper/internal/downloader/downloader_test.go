@@ -0,0 +1,353 @@
+package downloader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildTarGz(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for name, contents := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(contents)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, contents := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func newTestClient(t *testing.T) (Client, string) {
+	dir, err := ioutil.TempDir("", "dvmtest")
+	require.NoError(t, err)
+	d := Client{
+		log:            log.New(ioutil.Discard, "", 0),
+		tmp:            filepath.Join(dir, ".tmp"),
+		maxExtractSize: DefaultMaxExtractSize,
+		http:           &http.Client{},
+		readTimeout:    time.Second,
+		retries:        2,
+		retryDelay:     time.Millisecond,
+	}
+	return d, dir
+}
+
+func serveFiles(files map[string][]byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contents, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		w.Write(contents)
+	}))
+}
+
+func TestDownloadArchivedFiles(t *testing.T) {
+	archive := buildTarGz(t, map[string]string{
+		"docker/docker":  "client",
+		"docker/dockerd": "daemon",
+		"docker/runc":    "runc",
+	})
+	s := serveFiles(map[string][]byte{"/docker.tgz": archive})
+	defer s.Close()
+
+	d, dir := newTestClient(t)
+	defer os.RemoveAll(dir)
+
+	files := []ArchivedFile{
+		{Path: "docker/docker", DestPath: filepath.Join(dir, "bin", "docker")},
+		{Path: "docker/dockerd", DestPath: filepath.Join(dir, "bin", "dockerd"), Optional: true},
+		{Path: "docker/ctr", DestPath: filepath.Join(dir, "bin", "ctr"), Optional: true},
+	}
+	extracted, err := d.DownloadArchivedFiles(context.Background(), s.URL+"/docker.tgz", files)
+	require.NoError(t, err)
+	assert.Equal(t, files[:2], extracted, "Only the files in the archive should be extracted")
+
+	contents, _ := ioutil.ReadFile(filepath.Join(dir, "bin", "docker"))
+	assert.Equal(t, "client", string(contents))
+
+	entries, _ := ioutil.ReadDir(filepath.Join(dir, "bin"))
+	assert.Len(t, entries, 2, "Only the requested files should be written")
+	_, err = os.Stat(d.tmp)
+	assert.True(t, os.IsNotExist(err), "A gzipped archive should not be saved to the temp directory")
+}
+
+func TestDownloadArchivedFiles_Zip(t *testing.T) {
+	archive := buildZip(t, map[string]string{"docker/docker.exe": "client"})
+	s := serveFiles(map[string][]byte{"/docker.zip": archive})
+	defer s.Close()
+
+	d, dir := newTestClient(t)
+	defer os.RemoveAll(dir)
+
+	destPath := filepath.Join(dir, "docker.exe")
+	err := d.DownloadArchivedFile(context.Background(), s.URL+"/docker.zip", "docker/docker.exe", destPath)
+	require.NoError(t, err)
+
+	contents, _ := ioutil.ReadFile(destPath)
+	assert.Equal(t, "client", string(contents))
+}
+
+func TestDownloadArchivedFiles_Missing(t *testing.T) {
+	archive := buildTarGz(t, map[string]string{"docker/dockerd": "daemon"})
+	s := serveFiles(map[string][]byte{"/docker.tgz": archive})
+	defer s.Close()
+
+	d, dir := newTestClient(t)
+	defer os.RemoveAll(dir)
+
+	err := d.DownloadArchivedFile(context.Background(), s.URL+"/docker.tgz", "docker/docker", filepath.Join(dir, "docker"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Unable to find docker/docker")
+	}
+
+	entries, _ := ioutil.ReadDir(dir)
+	assert.Empty(t, entries, "Nothing should be left behind")
+}
+
+func TestDownloadArchivedFiles_Corrupt(t *testing.T) {
+	archive := buildTarGz(t, map[string]string{"docker/docker": "client"})
+	s := serveFiles(map[string][]byte{"/docker.tgz": archive[:len(archive)/2]})
+	defer s.Close()
+
+	d, dir := newTestClient(t)
+	defer os.RemoveAll(dir)
+
+	err := d.DownloadArchivedFile(context.Background(), s.URL+"/docker.tgz", "docker/docker", filepath.Join(dir, "docker"))
+	assert.Error(t, err, "A truncated archive should fail to extract")
+}
+
+func TestDownloadArchivedFilesWithChecksum(t *testing.T) {
+	archive := buildTarGz(t, map[string]string{"docker/docker": "client"})
+	checksum := fmt.Sprintf("%x  docker.tgz\n", sha256.Sum256(archive))
+	s := serveFiles(map[string][]byte{
+		"/docker.tgz":        archive,
+		"/docker.tgz.sha256": []byte(checksum),
+		"/bad.tgz":           archive,
+		"/bad.tgz.sha256":    []byte("0123456789abcdef  bad.tgz\n"),
+	})
+	defer s.Close()
+
+	d, dir := newTestClient(t)
+	defer os.RemoveAll(dir)
+
+	destPath := filepath.Join(dir, "docker")
+	err := d.DownloadArchivedFileWithChecksum(context.Background(), s.URL+"/docker.tgz", "docker/docker", destPath)
+	require.NoError(t, err)
+	_, err = os.Stat(destPath)
+	assert.NoError(t, err, "The archive should have been extracted")
+
+	badPath := filepath.Join(dir, "bad", "docker")
+	err = d.DownloadArchivedFileWithChecksum(context.Background(), s.URL+"/bad.tgz", "docker/docker", badPath)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "checksum")
+	}
+	entries, _ := ioutil.ReadDir(filepath.Dir(badPath))
+	assert.Empty(t, entries, "Files from an archive with an invalid checksum should not be saved")
+}
+
+func TestExtractArchivedFile(t *testing.T) {
+	d, dir := newTestClient(t)
+	defer os.RemoveAll(dir)
+
+	archivePath := filepath.Join(dir, "docker-20.10.24.tgz")
+	require.NoError(t, ioutil.WriteFile(archivePath, buildTarGz(t, map[string]string{"./docker/docker": "client"}), 0644))
+
+	destPath := filepath.Join(dir, "docker")
+	require.NoError(t, d.ExtractArchivedFile(archivePath, "docker/docker", destPath))
+
+	contents, _ := ioutil.ReadFile(destPath)
+	assert.Equal(t, "client", string(contents))
+	_, err := os.Stat(archivePath)
+	assert.NoError(t, err, "The local archive should not be removed")
+}
+
+func buildTarGzHeaders(t *testing.T, headers []*tar.Header) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for _, header := range headers {
+		require.NoError(t, tw.WriteHeader(header))
+		if header.Typeflag == tar.TypeReg {
+			_, err := tw.Write(make([]byte, header.Size))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestDownloadArchivedFiles_UnsafeEntries(t *testing.T) {
+	testcases := map[string]*tar.Header{
+		"traversal":         {Name: "docker/../../evil", Typeflag: tar.TypeReg, Mode: 0755, Size: 4},
+		"absolute":          {Name: "/etc/evil", Typeflag: tar.TypeReg, Mode: 0755, Size: 4},
+		"windows absolute":  {Name: `C:\evil`, Typeflag: tar.TypeReg, Mode: 0755, Size: 4},
+		"absolute symlink":  {Name: "docker/evil", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+		"escaping symlink":  {Name: "docker/evil", Typeflag: tar.TypeSymlink, Linkname: "../../etc/passwd"},
+		"escaping hardlink": {Name: "docker/evil", Typeflag: tar.TypeLink, Linkname: "../etc/passwd"},
+		"device":            {Name: "docker/evil", Typeflag: tar.TypeChar, Devmajor: 1, Devminor: 3},
+		"fifo":              {Name: "docker/evil", Typeflag: tar.TypeFifo},
+	}
+
+	for name, header := range testcases {
+		t.Run(name, func(t *testing.T) {
+			archive := buildTarGzHeaders(t, []*tar.Header{
+				{Name: "docker/docker", Typeflag: tar.TypeReg, Mode: 0755, Size: 6},
+				header,
+			})
+			s := serveFiles(map[string][]byte{"/docker.tgz": archive})
+			defer s.Close()
+
+			d, dir := newTestClient(t)
+			defer os.RemoveAll(dir)
+
+			destPath := filepath.Join(dir, "bin", "docker")
+			err := d.DownloadArchivedFile(context.Background(), s.URL+"/docker.tgz", "docker/docker", destPath)
+			require.Error(t, err)
+			_, ok := errors.Cause(err).(UnsafeEntryError)
+			assert.True(t, ok, "Expected an UnsafeEntryError but got %#v", err)
+
+			entries, _ := ioutil.ReadDir(filepath.Join(dir, "bin"))
+			assert.Empty(t, entries, "Nothing should be extracted from an unsafe archive")
+		})
+	}
+}
+
+func TestDownloadArchivedFiles_SafeSymlink(t *testing.T) {
+	archive := buildTarGzHeaders(t, []*tar.Header{
+		{Name: "docker/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "docker/docker", Typeflag: tar.TypeReg, Mode: 0755, Size: 6},
+		{Name: "docker/docker-latest", Typeflag: tar.TypeSymlink, Linkname: "docker"},
+	})
+	s := serveFiles(map[string][]byte{"/docker.tgz": archive})
+	defer s.Close()
+
+	d, dir := newTestClient(t)
+	defer os.RemoveAll(dir)
+
+	err := d.DownloadArchivedFile(context.Background(), s.URL+"/docker.tgz", "docker/docker", filepath.Join(dir, "docker"))
+	assert.NoError(t, err, "A symlink within the archive should be allowed")
+
+	err = d.DownloadArchivedFile(context.Background(), s.URL+"/docker.tgz", "docker/docker-latest", filepath.Join(dir, "docker-latest"))
+	require.Error(t, err, "A symlink should not be extracted in place of a file")
+	_, ok := errors.Cause(err).(UnsafeEntryError)
+	assert.True(t, ok, "Expected an UnsafeEntryError but got %#v", err)
+}
+
+func TestDownloadArchivedFiles_SizeLimit(t *testing.T) {
+	archive := buildTarGz(t, map[string]string{
+		"docker/docker":  "client",
+		"docker/dockerd": "daemon",
+	})
+	zipArchive := buildZip(t, map[string]string{
+		"docker/docker.exe":  "client",
+		"docker/dockerd.exe": "daemon",
+	})
+	s := serveFiles(map[string][]byte{"/docker.tgz": archive, "/docker.zip": zipArchive})
+	defer s.Close()
+
+	d, dir := newTestClient(t)
+	defer os.RemoveAll(dir)
+	d.maxExtractSize = 10
+
+	err := d.DownloadArchivedFile(context.Background(), s.URL+"/docker.tgz", "docker/docker", filepath.Join(dir, "docker"))
+	require.Error(t, err)
+	assert.Equal(t, SizeLimitError{Archive: "docker.tgz", Limit: 10}, errors.Cause(err))
+
+	err = d.DownloadArchivedFile(context.Background(), s.URL+"/docker.zip", "docker/docker.exe", filepath.Join(dir, "docker.exe"))
+	require.Error(t, err)
+	assert.Equal(t, SizeLimitError{Archive: "docker.zip", Limit: 10}, errors.Cause(err))
+
+	for _, name := range []string{"docker", "docker.exe"} {
+		_, err = os.Stat(filepath.Join(dir, name))
+		assert.True(t, os.IsNotExist(err), "Nothing should be extracted from an archive over the limit")
+	}
+}
+
+func TestDownloadArchivedFiles_UnsafeZipEntry(t *testing.T) {
+	archive := buildZip(t, map[string]string{
+		"docker/docker.exe": "client",
+		`..\evil.exe`:       "evil",
+	})
+	s := serveFiles(map[string][]byte{"/docker.zip": archive})
+	defer s.Close()
+
+	d, dir := newTestClient(t)
+	defer os.RemoveAll(dir)
+
+	err := d.DownloadArchivedFile(context.Background(), s.URL+"/docker.zip", "docker/docker.exe", filepath.Join(dir, "docker.exe"))
+	require.Error(t, err)
+	assert.Equal(t, UnsafeEntryError{Archive: "docker.zip", Entry: `..\evil.exe`, Reason: "is outside of the archive"}, errors.Cause(err))
+}
+
+func TestDownloadFile_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		cancel()
+		<-r.Context().Done()
+	}))
+	defer s.Close()
+
+	d, dir := newTestClient(t)
+	defer os.RemoveAll(dir)
+
+	destPath := filepath.Join(dir, "bin", "docker")
+	err := d.DownloadFile(ctx, s.URL+"/docker", destPath)
+	require.Error(t, err)
+
+	entries, _ := ioutil.ReadDir(filepath.Dir(destPath))
+	assert.Empty(t, entries, "A cancelled download should not leave a partial file")
+}
+
+func TestDownloadFile_NotFound(t *testing.T) {
+	s := serveFiles(map[string][]byte{})
+	defer s.Close()
+
+	d, dir := newTestClient(t)
+	defer os.RemoveAll(dir)
+
+	destPath := filepath.Join(dir, "docker")
+	err := d.DownloadFile(context.Background(), s.URL+"/docker", destPath)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Status 404")
+	}
+
+	_, err = os.Stat(destPath)
+	assert.True(t, os.IsNotExist(err), "A failed download should not create the destination file")
+}
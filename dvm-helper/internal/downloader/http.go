@@ -0,0 +1,205 @@
+package downloader
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The delay before the first retry, doubled after each attempt
+const defaultRetryDelay = time.Second
+
+// HTTPError is returned when a server responds with an unexpected status code.
+type HTTPError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e HTTPError) Error() string {
+	if e.StatusCode == http.StatusUnauthorized {
+		return fmt.Sprintf("Unable to download %s (Status %d), the mirror credentials are missing or were rejected", e.URL, e.StatusCode)
+	}
+	return fmt.Sprintf("Unable to download %s (Status %d)", e.URL, e.StatusCode)
+}
+
+// Transient server errors and rate limiting are worth retrying
+func (e HTTPError) isTransient() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// TruncatedError is returned when a download ends before the length sent by the server.
+type TruncatedError struct {
+	URL      string
+	Expected int64
+	Received int64
+}
+
+func (e TruncatedError) Error() string {
+	return fmt.Sprintf("The download of %s was truncated, received %d of %d bytes", e.URL, e.Received, e.Expected)
+}
+
+// TimeoutError is returned when a server stops sending data for longer than the read timeout.
+type TimeoutError struct {
+	URL     string
+	Timeout time.Duration
+}
+
+func (e TimeoutError) Error() string {
+	return fmt.Sprintf("The download of %s stalled, no data was received for %s", e.URL, e.Timeout)
+}
+
+// Call download until it succeeds, retrying transient failures with an exponential backoff
+func (d Client) withRetries(ctx context.Context, url string, download func() error) error {
+	delay := d.retryDelay
+	for attempt := 0; ; attempt++ {
+		err := download()
+		if err == nil || attempt >= d.retries || ctx.Err() != nil || !IsTransient(err) {
+			return err
+		}
+
+		d.log.Printf("Retrying %s in %s after attempt %d failed: %s\n", url, delay, attempt+1, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// IsTransient checks if an error may go away by trying again, e.g. a dropped connection or an overloaded server.
+func IsTransient(err error) bool {
+	cause := rootCause(err)
+	switch cause := cause.(type) {
+	case HTTPError:
+		return cause.isTransient()
+	case TruncatedError, TimeoutError:
+		return true
+	case net.Error:
+		return isTransientNetError(cause)
+	}
+	return cause == io.ErrUnexpectedEOF || cause == io.EOF
+}
+
+// IsUnavailable checks if an error means that a server can't be used right now,
+// because it can't be reached or it is failing, rather than the file not being found.
+func IsUnavailable(err error) bool {
+	if IsTransient(err) {
+		return true
+	}
+	_, ok := rootCause(err).(net.Error)
+	return ok
+}
+
+// Only timeouts and dropped connections are retried. A host that can't be found or refuses connections won't recover in time.
+func isTransientNetError(err net.Error) bool {
+	if err.Timeout() {
+		return true
+	}
+	return stderrors.Is(err, syscall.ECONNRESET) || stderrors.Is(err, syscall.ECONNABORTED) || stderrors.Is(err, syscall.EPIPE)
+}
+
+func rootCause(err error) error {
+	cause := errors.Cause(err)
+	if urlErr, ok := cause.(*neturl.Error); ok {
+		cause = urlErr.Err
+	}
+	return cause
+}
+
+// Send a request, failing unless the server responds with 200.
+// The request and reading the body are cancelled along with ctx.
+func (d Client) open(ctx context.Context, url string) (*http.Response, error) {
+	response, err := d.send(ctx, http.MethodGet, url)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, HTTPError{URL: url, StatusCode: response.StatusCode}
+	}
+	return response, nil
+}
+
+func (d Client) send(ctx context.Context, method string, url string) (*http.Response, error) {
+	requestCtx, cancel := context.WithCancel(ctx)
+	request, err := http.NewRequestWithContext(requestCtx, method, url, nil)
+	if err != nil {
+		cancel()
+		return nil, errors.Wrapf(err, "Invalid download URL %s", url)
+	}
+
+	response, err := d.http.Do(request)
+	if err != nil {
+		cancel()
+		return nil, errors.Wrapf(err, "Unable to download %s", url)
+	}
+
+	response.Body = newResponseBody(response, url, d.readTimeout, cancel)
+	return response, nil
+}
+
+// responseBody fails reads when the server stalls for longer than the read timeout,
+// or closes the connection before sending the whole body.
+type responseBody struct {
+	body        io.ReadCloser
+	url         string
+	expected    int64
+	received    int64
+	readTimeout time.Duration
+	timer       *time.Timer
+	timedOut    int32
+	cancel      context.CancelFunc
+}
+
+func newResponseBody(response *http.Response, url string, readTimeout time.Duration, cancel context.CancelFunc) *responseBody {
+	b := &responseBody{
+		body:        response.Body,
+		url:         url,
+		expected:    response.ContentLength,
+		readTimeout: readTimeout,
+		cancel:      cancel,
+	}
+	if readTimeout > 0 {
+		b.timer = time.AfterFunc(readTimeout, func() {
+			atomic.StoreInt32(&b.timedOut, 1)
+			cancel()
+		})
+	}
+	return b
+}
+
+func (b *responseBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.received += int64(n)
+
+	if atomic.LoadInt32(&b.timedOut) == 1 {
+		return n, TimeoutError{URL: b.url, Timeout: b.readTimeout}
+	}
+	if b.timer != nil {
+		b.timer.Reset(b.readTimeout)
+	}
+
+	if (err == io.EOF || err == io.ErrUnexpectedEOF) && b.expected >= 0 && b.received != b.expected {
+		return n, TruncatedError{URL: b.url, Expected: b.expected, Received: b.received}
+	}
+	return n, err
+}
+
+func (b *responseBody) Close() error {
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.cancel()
+	return b.body.Close()
+}
@@ -0,0 +1,221 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Respond with each status in turn, and then with the contents
+func serveFlaky(statuses []int, contents []byte, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempt := int(atomic.AddInt32(requests, 1)) - 1
+		if attempt < len(statuses) {
+			w.WriteHeader(statuses[attempt])
+			return
+		}
+		w.Write(contents)
+	}))
+}
+
+func TestDownloadFile_RetriesServerErrors(t *testing.T) {
+	var requests int32
+	s := serveFlaky([]int{503, 502}, []byte("client"), &requests)
+	defer s.Close()
+
+	d, dir := newTestClient(t)
+	defer os.RemoveAll(dir)
+
+	destPath := filepath.Join(dir, "docker")
+	require.NoError(t, d.DownloadFile(context.Background(), s.URL+"/docker", destPath))
+	assert.Equal(t, int32(3), requests)
+
+	contents, _ := ioutil.ReadFile(destPath)
+	assert.Equal(t, "client", string(contents))
+}
+
+func TestDownloadFile_RetriesExhausted(t *testing.T) {
+	var requests int32
+	s := serveFlaky([]int{500, 500, 500, 500}, []byte("client"), &requests)
+	defer s.Close()
+
+	d, dir := newTestClient(t)
+	defer os.RemoveAll(dir)
+
+	err := d.DownloadFile(context.Background(), s.URL+"/docker", filepath.Join(dir, "docker"))
+	require.Error(t, err)
+	assert.Equal(t, HTTPError{URL: s.URL + "/docker", StatusCode: 500}, errors.Cause(err))
+	assert.Equal(t, int32(3), requests, "The download should be attempted once and then retried twice")
+}
+
+func TestDownloadFile_DoesNotRetryClientErrors(t *testing.T) {
+	var requests int32
+	s := serveFlaky([]int{404}, []byte("client"), &requests)
+	defer s.Close()
+
+	d, dir := newTestClient(t)
+	defer os.RemoveAll(dir)
+
+	err := d.DownloadFile(context.Background(), s.URL+"/docker", filepath.Join(dir, "docker"))
+	require.Error(t, err)
+	assert.Equal(t, HTTPError{URL: s.URL + "/docker", StatusCode: 404}, errors.Cause(err))
+	assert.Equal(t, int32(1), requests)
+}
+
+func TestDownloadFile_Truncated(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte("partial"))
+	}))
+	defer s.Close()
+
+	d, dir := newTestClient(t)
+	defer os.RemoveAll(dir)
+	d.retries = 0
+
+	destPath := filepath.Join(dir, "docker")
+	err := d.DownloadFile(context.Background(), s.URL+"/docker", destPath)
+	require.Error(t, err)
+	assert.Equal(t, TruncatedError{URL: s.URL + "/docker", Expected: 100, Received: 7}, errors.Cause(err))
+
+	_, err = os.Stat(destPath)
+	assert.True(t, os.IsNotExist(err), "A truncated download should not be saved")
+}
+
+func TestDownloadFile_Stalled(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer s.Close()
+
+	d, dir := newTestClient(t)
+	defer os.RemoveAll(dir)
+	d.retries = 0
+	d.readTimeout = 50 * time.Millisecond
+
+	err := d.DownloadFile(context.Background(), s.URL+"/docker", filepath.Join(dir, "docker"))
+	require.Error(t, err)
+	assert.Equal(t, TimeoutError{URL: s.URL + "/docker", Timeout: d.readTimeout}, errors.Cause(err))
+}
+
+func TestDownloadArchivedFiles_RetriesTruncatedArchive(t *testing.T) {
+	archive := buildTarGz(t, map[string]string{"docker/docker": "client"})
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Write(archive[:len(archive)/2])
+			return
+		}
+		w.Write(archive)
+	}))
+	defer s.Close()
+
+	d, dir := newTestClient(t)
+	defer os.RemoveAll(dir)
+
+	destPath := filepath.Join(dir, "docker")
+	require.NoError(t, d.DownloadArchivedFile(context.Background(), s.URL+"/docker.tgz", "docker/docker", destPath))
+	assert.Equal(t, int32(2), requests)
+
+	contents, _ := ioutil.ReadFile(destPath)
+	assert.Equal(t, "client", string(contents))
+}
+
+func TestExists(t *testing.T) {
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/found":
+			w.WriteHeader(200)
+		case "/forbidden":
+			w.WriteHeader(403)
+		case "/missing":
+			w.WriteHeader(404)
+		case "/unauthorized":
+			w.WriteHeader(401)
+		default:
+			w.WriteHeader(503)
+		}
+	}))
+	defer s.Close()
+
+	d, dir := newTestClient(t)
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+
+	exists, err := d.Exists(ctx, s.URL+"/found")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	for _, path := range []string{"/forbidden", "/missing"} {
+		exists, err = d.Exists(ctx, s.URL+path)
+		assert.NoError(t, err)
+		assert.False(t, exists, "%s should not exist", path)
+	}
+
+	_, err = d.Exists(ctx, s.URL+"/unauthorized")
+	require.Error(t, err, "A missing or rejected login should not be reported as a missing file")
+	assert.Equal(t, HTTPError{URL: s.URL + "/unauthorized", StatusCode: 401}, errors.Cause(err))
+
+	atomic.StoreInt32(&requests, 0)
+	_, err = d.Exists(ctx, s.URL+"/unavailable")
+	require.Error(t, err)
+	assert.Equal(t, HTTPError{URL: s.URL + "/unavailable", StatusCode: 503}, errors.Cause(err))
+	assert.Equal(t, int32(3), requests)
+}
+
+func TestIsTransient(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	notFound := &net.DNSError{Err: "no such host", Name: "mirror.invalid", IsNotFound: true}
+	dnsTimeout := &net.DNSError{Err: "i/o timeout", Name: "mirror.example", IsTimeout: true}
+
+	testcases := []struct {
+		name            string
+		err             error
+		wantTransient   bool
+		wantUnavailable bool
+	}{
+		{"server error", HTTPError{StatusCode: http.StatusServiceUnavailable}, true, true},
+		{"rate limited", HTTPError{StatusCode: http.StatusTooManyRequests}, true, true},
+		{"not found", HTTPError{StatusCode: http.StatusNotFound}, false, false},
+		{"truncated", TruncatedError{}, true, true},
+		{"stalled", TimeoutError{}, true, true},
+		{"unexpected eof", &neturl.Error{Op: "Get", URL: "https://mirror.example", Err: io.ErrUnexpectedEOF}, true, true},
+		{"connection reset", &neturl.Error{Op: "Get", URL: "https://mirror.example", Err: reset}, true, true},
+		{"dns timeout", errors.Wrap(&neturl.Error{Op: "Get", URL: "https://mirror.example", Err: dnsTimeout}, "Unable to download"), true, true},
+		{"connection refused", &neturl.Error{Op: "Get", URL: "https://mirror.example", Err: refused}, false, true},
+		{"host not found", errors.Wrap(&neturl.Error{Op: "Get", URL: "https://mirror.invalid", Err: notFound}, "Unable to download"), false, true},
+		{"other", errors.New("checksum mismatch"), false, false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantTransient, IsTransient(tc.err), "IsTransient")
+			assert.Equal(t, tc.wantUnavailable, IsUnavailable(tc.err), "IsUnavailable")
+		})
+	}
+}
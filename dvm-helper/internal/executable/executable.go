@@ -0,0 +1,150 @@
+// Package executable checks that a file is a program built for the expected platform.
+package executable
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+	"os"
+)
+
+// InvalidError is returned when a file is not an executable for the expected platform.
+type InvalidError struct {
+	Path   string
+	Reason string
+}
+
+func (e InvalidError) Error() string {
+	return fmt.Sprintf("%s is not a valid executable: %s", e.Path, e.Reason)
+}
+
+var elfMachines = map[string]elf.Machine{
+	"386":     elf.EM_386,
+	"amd64":   elf.EM_X86_64,
+	"arm":     elf.EM_ARM,
+	"arm64":   elf.EM_AARCH64,
+	"ppc64le": elf.EM_PPC64,
+	"s390x":   elf.EM_S390,
+}
+
+var machoCpus = map[string]macho.Cpu{
+	"386":   macho.Cpu386,
+	"amd64": macho.CpuAmd64,
+	"arm64": macho.CpuArm64,
+}
+
+var peMachines = map[string]uint16{
+	"386":   pe.IMAGE_FILE_MACHINE_I386,
+	"amd64": pe.IMAGE_FILE_MACHINE_AMD64,
+	"arm64": pe.IMAGE_FILE_MACHINE_ARM64,
+}
+
+// Validate checks that the file at path is an executable for the specified GOOS and GOARCH.
+func Validate(path string, goos string, goarch string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch goos {
+	case "darwin":
+		return validateMachO(f, path, goarch)
+	case "windows":
+		return validatePE(f, path, goarch)
+	default:
+		return validateELF(f, path, goarch)
+	}
+}
+
+func validateELF(f *os.File, path string, goarch string) error {
+	file, err := elf.NewFile(f)
+	if err != nil {
+		return InvalidError{Path: path, Reason: describeContents(f, "an ELF binary")}
+	}
+
+	want, ok := elfMachines[goarch]
+	if !ok {
+		return InvalidError{Path: path, Reason: fmt.Sprintf("unsupported architecture %s", goarch)}
+	}
+	if file.Machine != want {
+		return InvalidError{Path: path, Reason: fmt.Sprintf("built for %s instead of %s", file.Machine, goarch)}
+	}
+	if goarch == "ppc64le" && file.ByteOrder.String() != "LittleEndian" {
+		return InvalidError{Path: path, Reason: "built for big endian ppc64 instead of ppc64le"}
+	}
+	if file.Type != elf.ET_EXEC && file.Type != elf.ET_DYN {
+		return InvalidError{Path: path, Reason: fmt.Sprintf("%s is not an executable", file.Type)}
+	}
+	return nil
+}
+
+func validateMachO(f *os.File, path string, goarch string) error {
+	want, ok := machoCpus[goarch]
+	if !ok {
+		return InvalidError{Path: path, Reason: fmt.Sprintf("unsupported architecture %s", goarch)}
+	}
+
+	if fat, err := macho.NewFatFile(f); err == nil {
+		for _, arch := range fat.Arches {
+			if arch.Cpu == want {
+				return nil
+			}
+		}
+		return InvalidError{Path: path, Reason: fmt.Sprintf("universal binary does not include %s", goarch)}
+	}
+
+	file, err := macho.NewFile(f)
+	if err != nil {
+		return InvalidError{Path: path, Reason: describeContents(f, "a Mach-O binary")}
+	}
+	if file.Cpu != want {
+		return InvalidError{Path: path, Reason: fmt.Sprintf("built for %s instead of %s", file.Cpu, goarch)}
+	}
+	if file.Type != macho.TypeExec {
+		return InvalidError{Path: path, Reason: "not an executable"}
+	}
+	return nil
+}
+
+func validatePE(f *os.File, path string, goarch string) error {
+	file, err := pe.NewFile(f)
+	if err != nil {
+		return InvalidError{Path: path, Reason: describeContents(f, "a PE binary")}
+	}
+
+	want, ok := peMachines[goarch]
+	if !ok {
+		return InvalidError{Path: path, Reason: fmt.Sprintf("unsupported architecture %s", goarch)}
+	}
+	if file.Machine != want {
+		return InvalidError{Path: path, Reason: fmt.Sprintf("built for machine type %#x instead of %s", file.Machine, goarch)}
+	}
+	if file.Characteristics&pe.IMAGE_FILE_EXECUTABLE_IMAGE == 0 {
+		return InvalidError{Path: path, Reason: "not an executable image"}
+	}
+	return nil
+}
+
+// describeContents explains what a file is when it isn't the expected kind of binary,
+// e.g. an error page returned by a mirror.
+func describeContents(f *os.File, want string) string {
+	header := make([]byte, 512)
+	n, _ := f.ReadAt(header, 0)
+	header = bytes.ToLower(bytes.TrimSpace(header[:n]))
+
+	switch {
+	case n == 0:
+		return "the file is empty"
+	case bytes.HasPrefix(header, []byte("<!doctype html")) || bytes.HasPrefix(header, []byte("<html")):
+		return fmt.Sprintf("expected %s but found an HTML page", want)
+	case bytes.HasPrefix(header, []byte("<?xml")):
+		return fmt.Sprintf("expected %s but found an XML document", want)
+	case bytes.HasPrefix(header, []byte("#!")):
+		return fmt.Sprintf("expected %s but found a script", want)
+	default:
+		return fmt.Sprintf("expected %s", want)
+	}
+}
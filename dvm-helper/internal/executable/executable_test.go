@@ -0,0 +1,39 @@
+package executable
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	// The test binary is an executable for the current platform
+	testBinary := os.Args[0]
+
+	err := Validate(testBinary, runtime.GOOS, runtime.GOARCH)
+	assert.NoError(t, err, "The test binary should be valid for the current platform")
+
+	otherArch := "arm64"
+	if runtime.GOARCH == "arm64" {
+		otherArch = "amd64"
+	}
+	err = Validate(testBinary, runtime.GOOS, otherArch)
+	assert.IsType(t, InvalidError{}, err, "The test binary should not be valid for %s", otherArch)
+}
+
+func TestValidate_ErrorPage(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "dvmtest")
+	errorPage := filepath.Join(tempDir, "docker")
+	ioutil.WriteFile(errorPage, []byte("<!DOCTYPE html>\n<html><body>Not Found</body></html>"), 0755)
+
+	for _, goos := range []string{"linux", "darwin", "windows"} {
+		err := Validate(errorPage, goos, "amd64")
+		if assert.IsType(t, InvalidError{}, err) {
+			assert.Contains(t, err.Error(), "HTML page")
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Package httpclient builds the HTTP client that dvm uses to talk to download mirrors and github.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Options configures how dvm connects to download mirrors and github.
+type Options struct {
+	// ConnectTimeout limits how long to wait when connecting and negotiating TLS, 0 waits indefinitely
+	ConnectTimeout time.Duration
+
+	// ReadTimeout limits how long to wait for a server to respond, 0 waits indefinitely
+	ReadTimeout time.Duration
+
+	// CAFile is a PEM bundle of certificate authorities to trust in addition to the system's
+	CAFile string
+
+	// ClientCert and ClientKey are PEM files used to authenticate with servers that require client certificates
+	ClientCert string
+	ClientKey  string
+
+	// Proxy is used for all requests instead of HTTP_PROXY and HTTPS_PROXY
+	Proxy string
+
+	// NoProxy is a comma separated list of hosts that are not proxied, instead of NO_PROXY
+	NoProxy string
+
+	// InsecureSkipVerify disables verification of server certificates
+	InsecureSkipVerify bool
+
+	// MirrorAuth holds the credentials for download mirrors
+	MirrorAuth MirrorAuth
+}
+
+// New creates an HTTP client.
+func New(o Options) (*http.Client, error) {
+	tlsConfig, err := buildTLSConfig(o)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := buildProxyFunc(o)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.TLSClientConfig = tlsConfig
+	transport.DialContext = (&net.Dialer{
+		Timeout:   o.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = o.ConnectTimeout
+	transport.ResponseHeaderTimeout = o.ReadTimeout
+
+	authenticated, err := newAuthTransport(transport, o.MirrorAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{Transport: authenticated}, nil
+}
+
+func buildTLSConfig(o Options) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+
+	if o.CAFile != "" {
+		pem, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to read the CA bundle %s", o.CAFile)
+		}
+
+		// Trust the bundle in addition to the system certificates, so that public servers like github still work
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("No certificates were found in the CA bundle %s", o.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if o.ClientCert != "" || o.ClientKey != "" {
+		if o.ClientCert == "" || o.ClientKey == "" {
+			return nil, errors.New("Both a client certificate and a client key are required")
+		}
+		cert, err := tls.LoadX509KeyPair(o.ClientCert, o.ClientKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to load the client certificate %s", o.ClientCert)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// Use the standard proxy environment variables, unless they are overridden
+func buildProxyFunc(o Options) (func(*http.Request) (*url.URL, error), error) {
+	proxyConfig := httpproxy.FromEnvironment()
+
+	if o.Proxy != "" {
+		if _, err := url.Parse(o.Proxy); err != nil {
+			return nil, errors.Wrapf(err, "Invalid proxy URL %s", o.Proxy)
+		}
+		proxyConfig.HTTPProxy = o.Proxy
+		proxyConfig.HTTPSProxy = o.Proxy
+	}
+	if o.NoProxy != "" {
+		proxyConfig.NoProxy = o.NoProxy
+	}
+
+	proxyFunc := proxyConfig.ProxyFunc()
+	return func(r *http.Request) (*url.URL, error) {
+		return proxyFunc(r.URL)
+	}, nil
+}
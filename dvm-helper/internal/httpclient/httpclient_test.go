@@ -0,0 +1,144 @@
+package httpclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Save the certificate that a test TLS server presents as a PEM bundle
+func writeServerCA(t *testing.T, s *httptest.Server, dir string) string {
+	caPath := filepath.Join(dir, "ca.pem")
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}
+	require.NoError(t, ioutil.WriteFile(caPath, pem.EncodeToMemory(block), 0644))
+	return caPath
+}
+
+// Generate a self-signed client certificate and key
+func writeClientCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "dvm"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath := filepath.Join(dir, "client.pem")
+	keyPath := filepath.Join(dir, "client-key.pem")
+	require.NoError(t, ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	require.NoError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certPath, keyPath
+}
+
+func TestNew_CAFile(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "dvmtest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	client, err := New(Options{})
+	require.NoError(t, err)
+	_, err = client.Get(s.URL)
+	assert.Error(t, err, "An untrusted server certificate should be rejected")
+
+	client, err = New(Options{CAFile: writeServerCA(t, s, dir)})
+	require.NoError(t, err)
+	response, err := client.Get(s.URL)
+	require.NoError(t, err, "The server certificate should be trusted with --ca-file")
+	response.Body.Close()
+
+	client, err = New(Options{InsecureSkipVerify: true})
+	require.NoError(t, err)
+	response, err = client.Get(s.URL)
+	require.NoError(t, err, "The server certificate should not be checked with --insecure-skip-verify")
+	response.Body.Close()
+}
+
+func TestNew_InvalidCAFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dvmtest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	caPath := filepath.Join(dir, "ca.pem")
+	require.NoError(t, ioutil.WriteFile(caPath, []byte("not a certificate"), 0644))
+
+	_, err = New(Options{CAFile: caPath})
+	assert.Error(t, err)
+
+	_, err = New(Options{CAFile: filepath.Join(dir, "missing.pem")})
+	assert.Error(t, err)
+}
+
+func TestNew_ClientCert(t *testing.T) {
+	var gotClientCert bool
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotClientCert = len(r.TLS.PeerCertificates) > 0
+	}))
+	s.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	s.StartTLS()
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "dvmtest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	caPath := writeServerCA(t, s, dir)
+	certPath, keyPath := writeClientCert(t, dir)
+
+	client, err := New(Options{CAFile: caPath})
+	require.NoError(t, err)
+	_, err = client.Get(s.URL)
+	assert.Error(t, err, "The server should require a client certificate")
+
+	client, err = New(Options{CAFile: caPath, ClientCert: certPath, ClientKey: keyPath})
+	require.NoError(t, err)
+	response, err := client.Get(s.URL)
+	require.NoError(t, err)
+	response.Body.Close()
+	assert.True(t, gotClientCert)
+
+	_, err = New(Options{ClientCert: certPath})
+	assert.Error(t, err, "A client certificate without a key should be rejected")
+}
+
+func TestNew_Proxy(t *testing.T) {
+	client, err := New(Options{Proxy: "http://proxy.example.com:3128", NoProxy: "internal.example.com"})
+	require.NoError(t, err)
+	proxy := client.Transport.(authTransport).base.(*http.Transport).Proxy
+
+	request, _ := http.NewRequest("GET", "https://download.docker.com/linux/static/stable/", nil)
+	proxyURL, err := proxy(request)
+	require.NoError(t, err)
+	if assert.NotNil(t, proxyURL) {
+		assert.Equal(t, "proxy.example.com:3128", proxyURL.Host)
+	}
+
+	request, _ = http.NewRequest("GET", "https://internal.example.com/docker.tgz", nil)
+	proxyURL, err = proxy(request)
+	require.NoError(t, err)
+	assert.Nil(t, proxyURL, "Hosts in the no proxy list should not be proxied")
+}
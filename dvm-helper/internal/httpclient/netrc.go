@@ -0,0 +1,103 @@
+package httpclient
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// netrcEntry is the login for a machine in a netrc file, an empty machine is the default login.
+type netrcEntry struct {
+	machine  string
+	login    string
+	password string
+}
+
+// Read the logins from a netrc file, a missing file has no logins
+func readNetrc(path string) ([]netrcEntry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "Unable to read the netrc file %s", path)
+	}
+	return parseNetrc(string(data)), nil
+}
+
+func parseNetrc(data string) []netrcEntry {
+	var entries []netrcEntry
+	var current *netrcEntry
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Macros run until the next blank line and never contain logins
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == "macdef" {
+			for scanner.Scan() && strings.TrimSpace(scanner.Text()) != "" {
+			}
+			continue
+		}
+
+		for i := 0; i < len(fields); i++ {
+			if strings.HasPrefix(fields[i], "#") {
+				break
+			}
+
+			switch fields[i] {
+			case "machine", "default":
+				if current != nil {
+					entries = append(entries, *current)
+				}
+				current = &netrcEntry{}
+				if fields[i] == "machine" && i+1 < len(fields) {
+					i++
+					current.machine = fields[i]
+				}
+			case "login", "password", "account":
+				if i+1 >= len(fields) {
+					continue
+				}
+				i++
+				if current == nil {
+					continue
+				}
+				if fields[i-1] == "login" {
+					current.login = fields[i]
+				} else if fields[i-1] == "password" {
+					current.password = fields[i]
+				}
+			}
+		}
+	}
+	if current != nil {
+		entries = append(entries, *current)
+	}
+	return entries
+}
+
+// Find the login for a host. The default login is only used when useDefault is set,
+// since it would otherwise be sent to every mirror.
+func findNetrcEntry(entries []netrcEntry, host string, useDefault bool) (netrcEntry, bool) {
+	var fallback *netrcEntry
+	for i, entry := range entries {
+		if entry.machine == "" {
+			if fallback == nil {
+				fallback = &entries[i]
+			}
+			continue
+		}
+		if strings.EqualFold(entry.machine, host) {
+			return entry, true
+		}
+	}
+	if useDefault && fallback != nil {
+		return *fallback, true
+	}
+	return netrcEntry{}, false
+}
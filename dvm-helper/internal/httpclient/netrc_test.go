@@ -0,0 +1,42 @@
+package httpclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNetrc(t *testing.T) {
+	data := `# mirrors
+machine example.com
+  login dvm
+  password secret
+
+macdef init
+  machine ignored.com login ignored password ignored
+
+machine other.com login other password other account unused
+default login anonymous password guest
+`
+	entries := parseNetrc(data)
+
+	assert.Equal(t, []netrcEntry{
+		{machine: "example.com", login: "dvm", password: "secret"},
+		{machine: "other.com", login: "other", password: "other"},
+		{machine: "", login: "anonymous", password: "guest"},
+	}, entries)
+
+	entry, ok := findNetrcEntry(entries, "EXAMPLE.com", false)
+	assert.True(t, ok)
+	assert.Equal(t, "dvm", entry.login)
+
+	_, ok = findNetrcEntry(entries, "unknown.com", false)
+	assert.False(t, ok, "The default login should only be used when requested")
+
+	entry, ok = findNetrcEntry(entries, "unknown.com", true)
+	assert.True(t, ok)
+	assert.Equal(t, "anonymous", entry.login)
+
+	_, ok = findNetrcEntry(entries[:2], "unknown.com", true)
+	assert.False(t, ok)
+}
@@ -0,0 +1,69 @@
+// Package lock provides advisory file locks, so that concurrent dvm commands don't modify DVM_DIR at the same time.
+package lock
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Lock is an exclusive advisory lock held on a file.
+type Lock struct {
+	file *os.File
+}
+
+// How often to check if another process has released the lock
+const pollInterval = 100 * time.Millisecond
+
+// Acquire takes an exclusive lock on the file at path, creating it when necessary.
+// When another process holds the lock, wait is called and then Acquire blocks until the lock is released or ctx is done.
+// wait - optional callback, e.g. to let the user know why the command is paused
+func Acquire(ctx context.Context, path string, wait func()) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, errors.Wrapf(err, "Unable to create parent directory %s", path)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to open %s", path)
+	}
+
+	locked, err := tryLockFile(file)
+	if err == nil && !locked && wait != nil {
+		wait()
+	}
+
+	// Poll rather than blocking on the lock, so that waiting can be cancelled
+	for err == nil && !locked {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-time.After(pollInterval):
+			locked, err = tryLockFile(file)
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, errors.Wrapf(err, "Unable to lock %s", path)
+	}
+
+	return &Lock{file: file}, nil
+}
+
+// Path is the location of the lock file.
+func (l *Lock) Path() string {
+	return l.file.Name()
+}
+
+// Release unlocks the file. Locks are also released when the process exits.
+func (l *Lock) Release() error {
+	err := unlockFile(l.file)
+	closeErr := l.file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return errors.Wrapf(err, "Unable to unlock %s", l.file.Name())
+}
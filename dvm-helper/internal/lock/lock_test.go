@@ -0,0 +1,64 @@
+package lock
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquire(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dvmtest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	lockPath := filepath.Join(dir, "dvm", ".lock")
+
+	first, err := Acquire(context.Background(), lockPath, nil)
+	require.NoError(t, err)
+	assert.Equal(t, lockPath, first.Path())
+
+	waited := make(chan bool, 1)
+	acquired := make(chan *Lock)
+	go func() {
+		second, err := Acquire(context.Background(), lockPath, func() { waited <- true })
+		assert.NoError(t, err)
+		acquired <- second
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("The lock should not be acquired while it is held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Len(t, waited, 1, "The wait callback should be called when the lock is held")
+
+	require.NoError(t, first.Release())
+	select {
+	case second := <-acquired:
+		assert.NoError(t, second.Release())
+	case <-time.After(5 * time.Second):
+		t.Fatal("The lock should be acquired once it is released")
+	}
+}
+
+func TestAcquire_Cancelled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dvmtest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	lockPath := filepath.Join(dir, ".lock")
+
+	held, err := Acquire(context.Background(), lockPath, nil)
+	require.NoError(t, err)
+	defer held.Release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err = Acquire(ctx, lockPath, cancel)
+	require.Error(t, err, "Waiting for the lock should stop when the context is cancelled")
+	assert.Equal(t, context.Canceled, errors.Cause(err))
+}
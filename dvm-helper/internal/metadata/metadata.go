@@ -0,0 +1,95 @@
+// Package metadata records details about installed Docker versions.
+package metadata
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FileName is the name of the metadata file saved in each version directory.
+const FileName = "metadata.json"
+
+// ClientInfo describes a Docker client binary.
+type ClientInfo struct {
+	Version    string
+	APIVersion string `json:"ApiVersion,omitempty"`
+	GitCommit  string `json:",omitempty"`
+	GoVersion  string `json:",omitempty"`
+	BuildTime  string `json:",omitempty"`
+	Os         string `json:",omitempty"`
+	Arch       string `json:",omitempty"`
+}
+
+// Install describes an installed Docker version.
+type Install struct {
+	Version     string
+	InstalledAt time.Time
+	Client      ClientInfo
+
+	// Link is the original location of a binary registered with `dvm link`
+	Link string `json:",omitempty"`
+}
+
+var bannerRegex = regexp.MustCompile(`(?i)version\s+v?([0-9][^\s,]*)(?:,\s*build\s+(\S+))?`)
+
+// ParseClientInfo reads the output of `docker version --format '{{json .Client}}'`.
+func ParseClientInfo(output []byte) (ClientInfo, error) {
+	var info ClientInfo
+	err := json.Unmarshal(output, &info)
+	if err != nil {
+		return ClientInfo{}, errors.Wrap(err, "Unable to parse the docker client version")
+	}
+	if info.Version == "" {
+		return ClientInfo{}, errors.New("The docker client did not report its version")
+	}
+	return info, nil
+}
+
+// ParseVersionBanner reads the output of `docker -v`, e.g. Docker version 1.12.1, build 23cf638.
+func ParseVersionBanner(output string) (ClientInfo, error) {
+	match := bannerRegex.FindStringSubmatch(strings.TrimSpace(output))
+	if match == nil {
+		return ClientInfo{}, errors.New("Could not detect docker version.")
+	}
+
+	return ClientInfo{
+		Version:   match[1],
+		GitCommit: match[2],
+	}, nil
+}
+
+// Load reads the metadata saved in a version directory.
+func Load(versionDir string) (Install, error) {
+	metadataPath := filepath.Join(versionDir, FileName)
+	contents, err := ioutil.ReadFile(metadataPath)
+	if err != nil {
+		return Install{}, errors.Wrapf(err, "Unable to read %s", metadataPath)
+	}
+
+	var m Install
+	err = json.Unmarshal(contents, &m)
+	return m, errors.Wrapf(err, "Unable to parse %s", metadataPath)
+}
+
+// Save writes the metadata to a version directory.
+func (m Install) Save(versionDir string) error {
+	metadataPath := filepath.Join(versionDir, FileName)
+	contents, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "Unable to serialize %s", metadataPath)
+	}
+
+	if err = os.MkdirAll(versionDir, 0755); err != nil {
+		return errors.Wrapf(err, "Unable to create %s", versionDir)
+	}
+
+	err = ioutil.WriteFile(metadataPath, contents, 0644)
+	return errors.Wrapf(err, "Unable to write %s", metadataPath)
+}
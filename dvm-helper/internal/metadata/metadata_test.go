@@ -0,0 +1,70 @@
+package metadata
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseClientInfo(t *testing.T) {
+	output := `{"Platform":{"Name":""},"Version":"20.10.24","ApiVersion":"1.41","DefaultAPIVersion":"1.41","GitCommit":"297e128","GoVersion":"go1.19.7","Os":"linux","Arch":"amd64","BuildTime":"Tue Apr  4 18:18:42 2023","Context":"default","Experimental":true}`
+
+	info, err := ParseClientInfo([]byte(output))
+	if err != nil {
+		t.Fatalf("%#v", err)
+	}
+	assert.Equal(t, "20.10.24", info.Version)
+	assert.Equal(t, "1.41", info.APIVersion)
+	assert.Equal(t, "297e128", info.GitCommit)
+	assert.Equal(t, "go1.19.7", info.GoVersion)
+	assert.Equal(t, "Tue Apr  4 18:18:42 2023", info.BuildTime)
+	assert.Equal(t, "linux", info.Os)
+	assert.Equal(t, "amd64", info.Arch)
+
+	_, err = ParseClientInfo([]byte("unknown flag: --format"))
+	assert.Error(t, err, "Output from clients which don't support --format should be rejected")
+}
+
+func TestParseVersionBanner(t *testing.T) {
+	testcases := map[string]ClientInfo{
+		"Docker version 1.12.1, build 23cf638":                    {Version: "1.12.1", GitCommit: "23cf638"},
+		"Docker version 17.06.0-ce, build 02c1d87\n":              {Version: "17.06.0-ce", GitCommit: "02c1d87"},
+		"Docker version 20.10.21+dfsg1, build baeda1f":            {Version: "20.10.21+dfsg1", GitCommit: "baeda1f"},
+		"Docker version 1.13.1, build 7d71120/1.13.1":             {Version: "1.13.1", GitCommit: "7d71120/1.13.1"},
+		"Docker version 1.6.2":                                    {Version: "1.6.2"},
+		"Docker Engine - Community version 24.0.5, build ced0996": {Version: "24.0.5", GitCommit: "ced0996"},
+	}
+
+	for output, want := range testcases {
+		got, err := ParseVersionBanner(output)
+		if err != nil {
+			t.Fatalf("%#v", err)
+		}
+		assert.Equal(t, want, got, "Unexpected client info for %s", output)
+	}
+
+	_, err := ParseVersionBanner("<html>Not Found</html>")
+	assert.Error(t, err)
+}
+
+func TestInstall_Save(t *testing.T) {
+	versionDir, _ := ioutil.TempDir("", "dvmtest")
+	m := Install{
+		Version:     "20.10.24",
+		InstalledAt: time.Date(2023, 4, 4, 18, 18, 42, 0, time.UTC),
+		Client:      ClientInfo{Version: "20.10.24", APIVersion: "1.41"},
+	}
+
+	err := m.Save(versionDir)
+	if err != nil {
+		t.Fatalf("%#v", err)
+	}
+
+	loaded, err := Load(versionDir)
+	if err != nil {
+		t.Fatalf("%#v", err)
+	}
+	assert.Equal(t, m, loaded)
+}
@@ -0,0 +1,317 @@
+// Package mirror chooses which download mirror dvm tries first, and remembers mirrors that recently failed.
+package mirror
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Default is the name used in a mirror list for Docker's own download servers.
+const Default = "default"
+
+// FailureTTL is how long a mirror that failed is tried after the other mirrors.
+const FailureTTL = 5 * time.Minute
+
+// The longest to wait for a mirror to respond when measuring latency
+const probeTimeout = 5 * time.Second
+
+// Strategy selects the order in which mirrors are tried.
+type Strategy string
+
+const (
+	// InOrder tries the mirrors in the order that they were configured.
+	InOrder Strategy = "order"
+
+	// ByLatency tries the mirror that responds the fastest first.
+	ByLatency Strategy = "latency"
+)
+
+// ParseStrategy validates the name of a strategy, defaulting to InOrder.
+func ParseStrategy(value string) (Strategy, error) {
+	switch Strategy(value) {
+	case "", InOrder:
+		return InOrder, nil
+	case ByLatency:
+		return ByLatency, nil
+	}
+	return "", errors.Errorf("Invalid mirror strategy %s. Available values are %s and %s.", value, InOrder, ByLatency)
+}
+
+// Parse splits a comma or whitespace separated list of mirrors.
+// The default mirror is represented by an empty string.
+func Parse(value string) []string {
+	var mirrors []string
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	}) {
+		if strings.EqualFold(field, Default) {
+			field = ""
+		}
+		mirrors = append(mirrors, field)
+	}
+	return mirrors
+}
+
+// ReadFile reads a list of mirrors, one per line. Lines starting with # are ignored.
+// A missing file has no mirrors.
+func ReadFile(path string) ([]string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "Unable to read the mirror list %s", path)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(string(contents)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return Parse(strings.Join(lines, "\n")), nil
+}
+
+// Name is how a mirror is described in messages.
+func Name(mirror string) string {
+	if mirror == "" {
+		return "docker.com"
+	}
+	return mirror
+}
+
+// List is the set of mirrors to download from.
+type List struct {
+	mirrors   []string
+	strategy  Strategy
+	statePath string
+	client    *http.Client
+	log       *log.Logger
+	now       func() time.Time
+
+	orderOnce sync.Once
+	order     []string
+
+	mu       sync.Mutex
+	loaded   bool
+	failures map[string]time.Time
+}
+
+// New creates a list of mirrors.
+// statePath - file where failing mirrors are remembered between commands, empty to only remember them in memory.
+func New(mirrors []string, strategy Strategy, statePath string, client *http.Client, logger *log.Logger) *List {
+	if len(mirrors) == 0 {
+		mirrors = []string{""}
+	}
+	return &List{
+		mirrors:   mirrors,
+		strategy:  strategy,
+		statePath: statePath,
+		client:    client,
+		log:       logger,
+		now:       time.Now,
+		failures:  make(map[string]time.Time),
+	}
+}
+
+// Len is the number of mirrors.
+func (l *List) Len() int {
+	return len(l.mirrors)
+}
+
+// Order lists the mirrors in the order they should be tried.
+// Mirrors that failed recently are tried last.
+func (l *List) Order(ctx context.Context) []string {
+	l.orderOnce.Do(func() {
+		l.order = l.mirrors
+		if l.strategy == ByLatency && len(l.mirrors) > 1 {
+			l.order = l.sortByLatency(ctx)
+		}
+	})
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.load()
+
+	var healthy, failing []string
+	for _, mirror := range l.order {
+		if l.isFailing(mirror) {
+			l.log.Printf("Trying %s last because it failed recently\n", Name(mirror))
+			failing = append(failing, mirror)
+			continue
+		}
+		healthy = append(healthy, mirror)
+	}
+	return append(healthy, failing...)
+}
+
+// Failed remembers that a mirror could not be reached or returned a server error.
+func (l *List) Failed(mirror string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.load()
+
+	l.failures[mirror] = l.now()
+	l.save()
+}
+
+// Succeeded forgets that a mirror previously failed.
+func (l *List) Succeeded(mirror string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.load()
+
+	if _, ok := l.failures[mirror]; !ok {
+		return
+	}
+	delete(l.failures, mirror)
+	l.save()
+}
+
+func (l *List) isFailing(mirror string) bool {
+	failedAt, ok := l.failures[mirror]
+	return ok && l.now().Sub(failedAt) < FailureTTL
+}
+
+// Measure how long each mirror takes to respond, and order them from fastest to slowest.
+// Mirrors that do not respond are tried last.
+func (l *List) sortByLatency(ctx context.Context) []string {
+	latencies := make([]time.Duration, len(l.mirrors))
+	var wg sync.WaitGroup
+	for i, mirror := range l.mirrors {
+		wg.Add(1)
+		go func(i int, mirror string) {
+			defer wg.Done()
+			latency, err := l.probe(ctx, mirror)
+			if err != nil {
+				if ctx.Err() == nil {
+					l.log.Printf("Unable to reach %s: %s\n", Name(mirror), err)
+					l.Failed(mirror)
+				}
+				latencies[i] = time.Duration(1<<63 - 1)
+				return
+			}
+			l.log.Printf("%s responded in %s\n", Name(mirror), latency)
+			latencies[i] = latency
+		}(i, mirror)
+	}
+	wg.Wait()
+
+	order := make([]int, len(l.mirrors))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return latencies[order[a]] < latencies[order[b]]
+	})
+
+	results := make([]string, len(order))
+	for i, index := range order {
+		results[i] = l.mirrors[index]
+	}
+	return results
+}
+
+// Any response counts, since a mirror may require credentials or not serve its root
+func (l *List) probe(ctx context.Context, mirror string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	location, err := ParseLocation(mirror)
+	if err != nil {
+		return 0, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, location.URL(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	response, err := l.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close()
+	return time.Since(start), nil
+}
+
+// Read the failures remembered by previous commands, ignoring any that have expired
+func (l *List) load() {
+	if l.loaded || l.statePath == "" {
+		return
+	}
+	l.loaded = true
+
+	contents, err := ioutil.ReadFile(l.statePath)
+	if err != nil {
+		return
+	}
+
+	var failures map[string]time.Time
+	if err := json.Unmarshal(contents, &failures); err != nil {
+		l.log.Printf("Ignoring the invalid mirror state %s: %s\n", l.statePath, err)
+		return
+	}
+	for mirror, failedAt := range failures {
+		if _, ok := l.failures[mirror]; !ok && l.now().Sub(failedAt) < FailureTTL {
+			l.failures[mirror] = failedAt
+		}
+	}
+}
+
+// Save the failures for the next command. Losing them is harmless, so errors are only logged.
+func (l *List) save() {
+	if l.statePath == "" {
+		return
+	}
+
+	for mirror := range l.failures {
+		if !l.isFailing(mirror) {
+			delete(l.failures, mirror)
+		}
+	}
+
+	contents, err := json.Marshal(l.failures)
+	if err != nil {
+		l.log.Printf("Unable to save the mirror state: %s\n", err)
+		return
+	}
+
+	dir := filepath.Dir(l.statePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		l.log.Printf("Unable to save the mirror state: %s\n", err)
+		return
+	}
+
+	// Replace the file in one step, other commands may be reading it
+	tmp, err := ioutil.TempFile(dir, filepath.Base(l.statePath)+"-")
+	if err != nil {
+		l.log.Printf("Unable to save the mirror state: %s\n", err)
+		return
+	}
+	_, err = tmp.Write(contents)
+	tmp.Close()
+	if err == nil {
+		err = os.Rename(tmp.Name(), l.statePath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		l.log.Printf("Unable to save the mirror state: %s\n", err)
+	}
+}
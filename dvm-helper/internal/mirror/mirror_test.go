@@ -0,0 +1,100 @@
+package mirror
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestList(mirrors []string, strategy Strategy, statePath string) *List {
+	return New(mirrors, strategy, statePath, &http.Client{}, log.New(ioutil.Discard, "", 0))
+}
+
+func TestParse(t *testing.T) {
+	assert.Equal(t, []string{"a.example.com", "https://b.example.com/docker", ""}, Parse("a.example.com, https://b.example.com/docker,,default"))
+	assert.Empty(t, Parse(""))
+}
+
+func TestReadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dvmtest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	mirrorsPath := filepath.Join(dir, "mirrors")
+	require.NoError(t, ioutil.WriteFile(mirrorsPath, []byte("# internal mirrors\na.example.com\n\n  b.example.com  \nDEFAULT\n"), 0644))
+
+	mirrors, err := ReadFile(mirrorsPath)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.example.com", "b.example.com", ""}, mirrors)
+
+	mirrors, err = ReadFile(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, mirrors)
+}
+
+func TestParseStrategy(t *testing.T) {
+	strategy, err := ParseStrategy("")
+	require.NoError(t, err)
+	assert.Equal(t, InOrder, strategy)
+
+	strategy, err = ParseStrategy("latency")
+	require.NoError(t, err)
+	assert.Equal(t, ByLatency, strategy)
+
+	_, err = ParseStrategy("random")
+	assert.Error(t, err)
+}
+
+func TestList_FailedMirrorsAreTriedLast(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dvmtest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, ".tmp", "mirrors.json")
+
+	mirrors := []string{"a.example.com", "b.example.com", ""}
+	l := newTestList(mirrors, InOrder, statePath)
+	assert.Equal(t, mirrors, l.Order(context.Background()))
+
+	l.Failed("a.example.com")
+	assert.Equal(t, []string{"b.example.com", "", "a.example.com"}, l.Order(context.Background()))
+
+	// The failure is remembered by the next command
+	next := newTestList(mirrors, InOrder, statePath)
+	assert.Equal(t, []string{"b.example.com", "", "a.example.com"}, next.Order(context.Background()))
+
+	// Until it expires
+	next.now = func() time.Time { return time.Now().Add(FailureTTL) }
+	assert.Equal(t, mirrors, next.Order(context.Background()))
+
+	l.Succeeded("a.example.com")
+	assert.Equal(t, mirrors, l.Order(context.Background()))
+	assert.Equal(t, mirrors, newTestList(mirrors, InOrder, statePath).Order(context.Background()))
+}
+
+func TestList_ByLatency(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer fast.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	down.Close()
+
+	l := newTestList([]string{down.URL, slow.URL, fast.URL}, ByLatency, "")
+	assert.Equal(t, []string{fast.URL, slow.URL, down.URL}, l.Order(context.Background()))
+
+	l = newTestList([]string{down.URL, slow.URL, fast.URL}, InOrder, "")
+	assert.Equal(t, []string{down.URL, slow.URL, fast.URL}, l.Order(context.Background()), "Mirrors should only be measured with the latency strategy")
+}
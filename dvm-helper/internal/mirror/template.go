@@ -0,0 +1,170 @@
+package mirror
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultTemplate is the layout of download.docker.com, which is used for mirrors unless another template is specified.
+const DefaultTemplate Template = "{scheme}://{host}/{prefix}/{os}/static/{channel}/{arch}/docker-{version}{ext}"
+
+var placeholderRegex = regexp.MustCompile(`\{[a-z]+\}`)
+
+var placeholders = map[string]bool{
+	"{scheme}": true, "{host}": true, "{prefix}": true,
+	"{os}": true, "{channel}": true, "{arch}": true, "{version}": true, "{ext}": true,
+}
+
+// Location is where a mirror is hosted.
+type Location struct {
+	Scheme string
+
+	// Host includes the port, if any
+	Host string
+
+	// Prefix is the path under which the mirror is hosted, without leading or trailing slashes
+	Prefix string
+
+	// HostOnly is set when the mirror was only a host, e.g. example.com, rather than a URL
+	HostOnly bool
+}
+
+// ParseLocation reads a mirror, which may be a full URL with a path prefix, e.g. http://example.com/docker,
+// or only a host which is served over https. The default mirror is download.docker.com.
+func ParseLocation(mirror string) (Location, error) {
+	if mirror == "" {
+		return Location{Scheme: "https", Host: "download.docker.com"}, nil
+	}
+
+	if !strings.Contains(mirror, "://") {
+		u, err := url.Parse("//" + mirror)
+		if err != nil || u.Host == "" {
+			return Location{}, errors.Errorf("Invalid mirror %s", mirror)
+		}
+		return Location{Scheme: "https", Host: u.Host, Prefix: strings.Trim(u.Path, "/"), HostOnly: u.Path == "" || u.Path == "/"}, nil
+	}
+
+	u, err := url.Parse(mirror)
+	if err != nil || u.Host == "" {
+		return Location{}, errors.Errorf("Invalid mirror %s", mirror)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Location{}, errors.Errorf("Invalid mirror %s, only http and https are supported", mirror)
+	}
+	return Location{Scheme: u.Scheme, Host: u.Host, Prefix: strings.Trim(u.Path, "/")}, nil
+}
+
+// URL is the root of the mirror.
+func (l Location) URL() string {
+	if l.Prefix == "" {
+		return l.Scheme + "://" + l.Host + "/"
+	}
+	return l.Scheme + "://" + l.Host + "/" + l.Prefix + "/"
+}
+
+// Template is the layout of a mirror, e.g. {scheme}://{host}/{prefix}/{os}/static/{channel}/{arch}/docker-{version}{ext}.
+type Template string
+
+// Vars are the values substituted into a template.
+type Vars struct {
+	OS      string
+	Channel string
+	Arch    string
+	Version string
+	Ext     string
+}
+
+// ParseTemplate validates a template, defaulting to DefaultTemplate.
+// Releases are listed from the template's directory, so {version} must be in the file name.
+func ParseTemplate(value string) (Template, error) {
+	if value == "" {
+		return DefaultTemplate, nil
+	}
+
+	for _, placeholder := range placeholderRegex.FindAllString(value, -1) {
+		if !placeholders[placeholder] {
+			return "", errors.Errorf("Invalid mirror template %s, %s is not a supported placeholder", value, placeholder)
+		}
+	}
+
+	slash := strings.LastIndex(value, "/")
+	if !strings.Contains(value[slash+1:], "{version}") {
+		return "", errors.Errorf("Invalid mirror template %s, the file name must contain {version}", value)
+	}
+	if strings.Contains(value[:slash+1], "{version}") {
+		return "", errors.Errorf("Invalid mirror template %s, only the file name may contain {version}", value)
+	}
+
+	return Template(value), nil
+}
+
+// Expand builds the URL of a release on a mirror.
+func (t Template) Expand(l Location, vars Vars) string {
+	if t == "" {
+		t = DefaultTemplate
+	}
+
+	var replacements []string
+	for placeholder, value := range placeholderValues(l, vars) {
+		replacements = append(replacements, placeholder, value)
+	}
+	expanded := strings.NewReplacer(replacements...).Replace(string(t))
+
+	// An empty prefix leaves behind a double slash
+	scheme := ""
+	if i := strings.Index(expanded, "://"); i >= 0 {
+		scheme, expanded = expanded[:i+3], expanded[i+3:]
+	}
+	for strings.Contains(expanded, "//") {
+		expanded = strings.Replace(expanded, "//", "/", -1)
+	}
+	return scheme + expanded
+}
+
+// The value of each placeholder, for a release on a mirror
+func placeholderValues(l Location, vars Vars) map[string]string {
+	return map[string]string{
+		"{scheme}":  l.Scheme,
+		"{host}":    l.Host,
+		"{prefix}":  l.Prefix,
+		"{os}":      vars.OS,
+		"{channel}": vars.Channel,
+		"{arch}":    vars.Arch,
+		"{version}": vars.Version,
+		"{ext}":     vars.Ext,
+	}
+}
+
+// Dir builds the URL of the directory which lists the releases on a mirror.
+func (t Template) Dir(l Location, vars Vars) string {
+	expanded := t.Expand(l, vars)
+	return expanded[:strings.LastIndex(expanded, "/")]
+}
+
+// FileRegex matches links to releases in a directory listing, capturing the version.
+func (t Template) FileRegex(l Location, vars Vars) *regexp.Regexp {
+	if t == "" {
+		t = DefaultTemplate
+	}
+	fileName := string(t)[strings.LastIndex(string(t), "/")+1:]
+	values := placeholderValues(l, vars)
+
+	var pattern strings.Builder
+	last := 0
+	for _, loc := range placeholderRegex.FindAllStringIndex(fileName, -1) {
+		pattern.WriteString(regexp.QuoteMeta(fileName[last:loc[0]]))
+		if placeholder := fileName[loc[0]:loc[1]]; placeholder == "{version}" {
+			pattern.WriteString("(.+?)")
+		} else {
+			pattern.WriteString(regexp.QuoteMeta(values[placeholder]))
+		}
+		last = loc[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(fileName[last:]))
+
+	// Links may be relative to the directory or include its path
+	return regexp.MustCompile(`href="(?:[^"]*/)?` + pattern.String() + `"`)
+}
@@ -0,0 +1,98 @@
+package mirror
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLocation(t *testing.T) {
+	testcases := []struct {
+		mirror string
+		want   Location
+	}{
+		{"", Location{Scheme: "https", Host: "download.docker.com"}},
+		{"example.com:8443", Location{Scheme: "https", Host: "example.com:8443", HostOnly: true}},
+		{"example.com/docker/", Location{Scheme: "https", Host: "example.com", Prefix: "docker"}},
+		{"http://artifacts.corp/docker-remote/", Location{Scheme: "http", Host: "artifacts.corp", Prefix: "docker-remote"}},
+		{"https://artifacts.corp", Location{Scheme: "https", Host: "artifacts.corp"}},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.mirror, func(t *testing.T) {
+			got, err := ParseLocation(tc.mirror)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	_, err := ParseLocation("ftp://example.com")
+	assert.Error(t, err, "Only http and https mirrors should be supported")
+}
+
+func TestParseTemplate(t *testing.T) {
+	template, err := ParseTemplate("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultTemplate, template)
+
+	_, err = ParseTemplate("{scheme}://{host}/{prefix}/{platform}/docker-{version}{ext}")
+	assert.Error(t, err, "Unknown placeholders should be rejected")
+
+	_, err = ParseTemplate("{scheme}://{host}/{version}/docker{ext}")
+	assert.Error(t, err, "The version must be in the file name so that releases can be listed")
+}
+
+func TestTemplate_Expand(t *testing.T) {
+	vars := Vars{OS: "linux", Channel: "stable", Arch: "x86_64", Version: "20.10.24", Ext: ".tgz"}
+
+	location, err := ParseLocation("http://artifacts.corp/docker-remote/")
+	require.NoError(t, err)
+	assert.Equal(t, "http://artifacts.corp/docker-remote/linux/static/stable/x86_64/docker-20.10.24.tgz", DefaultTemplate.Expand(location, vars))
+	assert.Equal(t, "http://artifacts.corp/docker-remote/linux/static/stable/x86_64", DefaultTemplate.Dir(location, vars))
+
+	location, err = ParseLocation("")
+	require.NoError(t, err)
+	assert.Equal(t, "https://download.docker.com/linux/static/stable/x86_64/docker-20.10.24.tgz", DefaultTemplate.Expand(location, vars),
+		"An empty prefix should not leave a double slash")
+
+	custom := Template("{scheme}://{host}/{prefix}/{channel}/docker-{os}-{arch}-{version}{ext}")
+	assert.Equal(t, "https://download.docker.com/stable/docker-linux-x86_64-20.10.24.tgz", custom.Expand(location, vars))
+}
+
+func TestTemplate_FileRegex(t *testing.T) {
+	location, err := ParseLocation("")
+	require.NoError(t, err)
+	vars := Vars{OS: "linux", Channel: "stable", Arch: "x86_64", Ext: ".tgz"}
+	listing := `<a href="docker-20.10.24.tgz">docker-20.10.24.tgz</a>
+<a href="/docker-remote/linux/static/stable/x86_64/docker-20.10.25.tgz">docker-20.10.25.tgz</a>
+<a href="docker-20.10.25.tgz.sha256">docker-20.10.25.tgz.sha256</a>
+<a href="docker-linux-x86_64-20.10.26.tgz">docker-linux-x86_64-20.10.26.tgz</a>`
+
+	var versions []string
+	for _, match := range DefaultTemplate.FileRegex(location, vars).FindAllStringSubmatch(listing, -1) {
+		versions = append(versions, match[1])
+	}
+	assert.Equal(t, []string{"20.10.24", "20.10.25", "linux-x86_64-20.10.26"}, versions)
+
+	custom := Template("{scheme}://{host}/{prefix}/{channel}/docker-{os}-{arch}-{version}{ext}")
+	match := custom.FileRegex(location, vars).FindStringSubmatch(listing)
+	require.NotNil(t, match)
+	assert.Equal(t, "20.10.26", match[1])
+}
+
+func TestTemplate_FileRegex_Location(t *testing.T) {
+	location, err := ParseLocation("http://artifacts.corp/docker-remote")
+	require.NoError(t, err)
+	vars := Vars{OS: "linux", Channel: "stable", Arch: "x86_64", Ext: ".tgz"}
+	listing := `<a href="docker-remote-artifacts.corp-20.10.24.tgz">docker-remote-artifacts.corp-20.10.24.tgz</a>
+<a href="docker-remote-other.corp-20.10.25.tgz">docker-remote-other.corp-20.10.25.tgz</a>
+<a href="{prefix}-{host}-20.10.26.tgz">{prefix}-{host}-20.10.26.tgz</a>`
+
+	custom := Template("{scheme}://{host}/{prefix}/{channel}/{prefix}-{host}-{version}{ext}")
+	var versions []string
+	for _, match := range custom.FileRegex(location, vars).FindAllStringSubmatch(listing, -1) {
+		versions = append(versions, match[1])
+	}
+	assert.Equal(t, []string{"20.10.24"}, versions, "The host and prefix in the file name should match the mirror")
+}
@@ -0,0 +1,186 @@
+// Package platform describes the operating systems and architectures that Docker publishes clients for.
+package platform
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Platform identifies the operating system and architecture of a Docker client.
+type Platform struct {
+	// OS is the GOOS of the platform, e.g. linux
+	OS string
+
+	// Arch is the architecture name used by Docker releases, e.g. x86_64
+	Arch string
+}
+
+type osInfo struct {
+	dockerOS       string
+	mobyOS         string
+	archiveFileExt string
+	binaryFileExt  string
+}
+
+var oses = map[string]osInfo{
+	"linux":   {dockerOS: "Linux", mobyOS: "linux", archiveFileExt: ".tgz"},
+	"darwin":  {dockerOS: "Darwin", mobyOS: "mac", archiveFileExt: ".tgz"},
+	"windows": {dockerOS: "Windows", mobyOS: "win", archiveFileExt: ".zip", binaryFileExt: ".exe"},
+}
+
+var osAliases = map[string]string{
+	"mac":   "darwin",
+	"macos": "darwin",
+	"osx":   "darwin",
+	"win":   "windows",
+}
+
+// Docker architecture names, keyed by GOARCH
+// 32-bit arm is published as armhf (ARMv7) and armel (ARMv6 and below)
+var arches = map[string]string{
+	"386":     "i386",
+	"amd64":   "x86_64",
+	"arm64":   "aarch64",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+}
+
+var armArches = map[string]bool{
+	"armhf": true,
+	"armel": true,
+}
+
+// Current is the platform that dvm is running on.
+func Current() Platform {
+	return Platform{
+		OS:   runtime.GOOS,
+		Arch: currentArch(),
+	}
+}
+
+func currentArch() string {
+	if runtime.GOARCH != "arm" {
+		return arches[runtime.GOARCH]
+	}
+
+	// Go defaults to GOARM=7 when cross-compiling for arm
+	goarm := "7"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "GOARM" && setting.Value != "" {
+				goarm = setting.Value
+			}
+		}
+	}
+	return armArch(goarm)
+}
+
+// armArch is the Docker architecture for a GOARM version
+func armArch(goarm string) string {
+	if strings.HasPrefix(goarm, "5") || strings.HasPrefix(goarm, "6") {
+		return "armel"
+	}
+	return "armhf"
+}
+
+// Parse a platform from an operating system and architecture, using the current platform for either when empty.
+// Both GOOS/GOARCH and Docker's names are accepted, e.g. darwin or mac, amd64 or x86_64.
+func Parse(osName string, archName string) (Platform, error) {
+	p := Current()
+
+	if osName != "" {
+		osName = strings.ToLower(osName)
+		if alias, ok := osAliases[osName]; ok {
+			osName = alias
+		}
+		if _, ok := oses[osName]; !ok {
+			return Platform{}, errors.Errorf("Unsupported operating system %s, expected one of: %s", osName, strings.Join(SupportedOSes(), ", "))
+		}
+		p.OS = osName
+	}
+
+	if archName != "" {
+		archName = strings.ToLower(archName)
+		if dockerArch, ok := arches[archName]; ok {
+			archName = dockerArch
+		} else if archName == "arm" {
+			archName = "armhf"
+		}
+		if _, ok := findGOARCH(archName); !ok {
+			return Platform{}, errors.Errorf("Unsupported architecture %s, expected one of: %s", archName, strings.Join(SupportedArches(), ", "))
+		}
+		p.Arch = archName
+	}
+
+	if p.Arch == "" {
+		return Platform{}, errors.Errorf("Docker does not publish clients for %s, specify the architecture", runtime.GOARCH)
+	}
+
+	return p, nil
+}
+
+// SupportedOSes lists the operating systems that can be specified.
+func SupportedOSes() []string {
+	return []string{"darwin", "linux", "windows"}
+}
+
+// SupportedArches lists the architectures that can be specified, using Docker's names.
+func SupportedArches() []string {
+	return []string{"aarch64", "armel", "armhf", "i386", "ppc64le", "s390x", "x86_64"}
+}
+
+func findGOARCH(arch string) (string, bool) {
+	if armArches[arch] {
+		return "arm", true
+	}
+	for goarch, dockerArch := range arches {
+		if dockerArch == arch {
+			return goarch, true
+		}
+	}
+	return "", false
+}
+
+// String formats the platform as os/arch, e.g. linux/x86_64.
+func (p Platform) String() string {
+	return p.OS + "/" + p.Arch
+}
+
+// IsCurrent checks if the platform is the one that dvm is running on.
+func (p Platform) IsCurrent() bool {
+	return p == Current()
+}
+
+// GOARCH is the Go name for the architecture, e.g. amd64.
+func (p Platform) GOARCH() string {
+	goarch, _ := findGOARCH(p.Arch)
+	return goarch
+}
+
+// IsCurrentArch checks if the platform's architecture is the one that dvm is running on.
+func (p Platform) IsCurrentArch() bool {
+	return p.Arch == Current().Arch
+}
+
+// DockerOS is the operating system name used by the original get.docker.com downloads, e.g. Linux.
+func (p Platform) DockerOS() string {
+	return oses[p.OS].dockerOS
+}
+
+// MobyOS is the operating system name used by download.docker.com, e.g. mac.
+func (p Platform) MobyOS() string {
+	return oses[p.OS].mobyOS
+}
+
+// ArchiveFileExt is the extension of release archives, e.g. .tgz.
+func (p Platform) ArchiveFileExt() string {
+	return oses[p.OS].archiveFileExt
+}
+
+// BinaryFileExt is the extension of executables, e.g. .exe.
+func (p Platform) BinaryFileExt() string {
+	return oses[p.OS].binaryFileExt
+}
@@ -0,0 +1,75 @@
+package platform
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	testcases := map[string]struct {
+		os, arch string
+		want     Platform
+	}{
+		"docker names": {os: "linux", arch: "x86_64", want: Platform{OS: "linux", Arch: "x86_64"}},
+		"go names":     {os: "darwin", arch: "arm64", want: Platform{OS: "darwin", Arch: "aarch64"}},
+		"moby os":      {os: "mac", arch: "aarch64", want: Platform{OS: "darwin", Arch: "aarch64"}},
+		"mixed case":   {os: "Windows", arch: "AMD64", want: Platform{OS: "windows", Arch: "x86_64"}},
+		"default arch": {os: "windows", want: Platform{OS: "windows", Arch: Current().Arch}},
+		"default os":   {arch: "i386", want: Platform{OS: runtime.GOOS, Arch: "i386"}},
+		"armhf":        {os: "linux", arch: "armhf", want: Platform{OS: "linux", Arch: "armhf"}},
+		"armel":        {os: "linux", arch: "armel", want: Platform{OS: "linux", Arch: "armel"}},
+		"go arm":       {os: "linux", arch: "arm", want: Platform{OS: "linux", Arch: "armhf"}},
+		"ppc64le":      {os: "linux", arch: "ppc64le", want: Platform{OS: "linux", Arch: "ppc64le"}},
+		"s390x":        {os: "linux", arch: "s390x", want: Platform{OS: "linux", Arch: "s390x"}},
+	}
+
+	for name, tc := range testcases {
+		got, err := Parse(tc.os, tc.arch)
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, tc.want, got, name)
+		}
+	}
+}
+
+func TestParse_Unsupported(t *testing.T) {
+	_, err := Parse("plan9", "")
+	assert.Error(t, err, "plan9 is not a supported operating system")
+
+	_, err = Parse("", "mips")
+	assert.Error(t, err, "mips is not a supported architecture")
+}
+
+func TestPlatform_Windows(t *testing.T) {
+	p, _ := Parse("windows", "x86_64")
+	assert.Equal(t, "Windows", p.DockerOS())
+	assert.Equal(t, "win", p.MobyOS())
+	assert.Equal(t, ".zip", p.ArchiveFileExt())
+	assert.Equal(t, ".exe", p.BinaryFileExt())
+	assert.Equal(t, "amd64", p.GOARCH())
+	assert.Equal(t, "windows/x86_64", p.String())
+}
+
+func TestArmArch(t *testing.T) {
+	assert.Equal(t, "armel", armArch("5"))
+	assert.Equal(t, "armel", armArch("6"))
+	assert.Equal(t, "armhf", armArch("7"))
+	assert.Equal(t, "armhf", armArch("7,softfloat"))
+}
+
+func TestPlatform_GOARCH(t *testing.T) {
+	testcases := map[string]string{
+		"x86_64":  "amd64",
+		"i386":    "386",
+		"aarch64": "arm64",
+		"armhf":   "arm",
+		"armel":   "arm",
+		"ppc64le": "ppc64le",
+		"s390x":   "s390x",
+	}
+
+	for arch, want := range testcases {
+		assert.Equal(t, want, Platform{OS: "linux", Arch: arch}.GOARCH(), arch)
+	}
+}
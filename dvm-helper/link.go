@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
+	"github.com/howtowhale/dvm/dvm-helper/internal/metadata"
+)
+
+// Register an external docker client, such as a locally built binary, as a named version
+// name - the name used to refer to the binary, e.g. with `dvm use`
+// binaryPath - location of the docker client
+// copy - copy the binary instead of symlinking to it
+func link(name string, binaryPath string, copy bool) {
+	lockDvmDir()
+	validateLinkName(name)
+
+	binaryPath, err := filepath.Abs(binaryPath)
+	if err != nil {
+		die("Unable to resolve %s.", err, retCodeInvalidArgument, binaryPath)
+	}
+	if _, err = os.Stat(binaryPath); err != nil {
+		die("Unable to read %s.", err, retCodeInvalidArgument, binaryPath)
+	}
+
+	err = validateDockerBinary(binaryPath, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		die("%s is not a usable docker client.", err, retCodeInvalidArgument, binaryPath)
+	}
+
+	version := dockerversion.Parse(name)
+	versionDir := getVersionDir(version)
+	if _, err = os.Stat(versionDir); err == nil {
+		die("%s is already installed. Run `dvm uninstall %s` first to replace it.", nil, retCodeInvalidOperation, name, name)
+	}
+
+	stagingDir := newStagingDir()
+	defer os.RemoveAll(stagingDir)
+	stagedVersionDir := filepath.Join(stagingDir, filepath.Base(versionDir))
+	if err = os.MkdirAll(stagedVersionDir, os.ModePerm); err != nil {
+		die("Unable to create %s.", err, retCodeRuntimeError, stagedVersionDir)
+	}
+
+	destPath := filepath.Join(stagedVersionDir, getBinaryName())
+	if !copy {
+		err = os.Symlink(binaryPath, destPath)
+		if err != nil {
+			writeWarning("Unable to symlink to %s, copying it instead: %s", binaryPath, err)
+			copy = true
+		}
+	}
+	if copy {
+		err = copyFile(binaryPath, destPath, 0755)
+		if err != nil {
+			os.RemoveAll(stagingDir)
+			die("Unable to copy %s.", err, retCodeRuntimeError, binaryPath)
+		}
+	}
+
+	m := metadata.Install{
+		Version:     name,
+		InstalledAt: time.Now().UTC(),
+		Link:        binaryPath,
+	}
+	info, err := inspectDockerClient(destPath)
+	if err != nil {
+		writeWarning("Unable to detect the version of %s: %s", binaryPath, err)
+	} else {
+		m.Client = info
+	}
+	if err = m.Save(stagedVersionDir); err != nil {
+		writeWarning("Unable to save the link metadata for %s: %s", name, err)
+	}
+	commitStagedVersion(version, stagedVersionDir)
+
+	writeInfo("Linked %s to %s", name, binaryPath)
+}
+
+// Link names share the version directory with installed versions and the alias namespace with aliases
+func validateLinkName(name string) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		die("%s is not a valid link name.", nil, retCodeInvalidArgument, name)
+	}
+
+	version := dockerversion.Parse(name)
+	if !version.IsEmpty() {
+		die("Link names cannot be a version number, %s is reserved for Docker %s.", nil, retCodeInvalidArgument, name, name)
+	}
+	if version.IsSystem() || version.IsEdge() {
+		die("%s is a reserved name.", nil, retCodeInvalidArgument, name)
+	}
+	if aliasExists(name) {
+		die("%s is already an alias. Run `dvm unalias %s` first to use it as a link name.", nil, retCodeInvalidOperation, name, name)
+	}
+}
+
+// Check if a version was registered with `dvm link`
+func isLinkedVersion(version dockerversion.Version) bool {
+	if !version.IsEmpty() || version.IsSystem() || version.IsEdge() || version.Name() == "" {
+		return false
+	}
+
+	m, err := metadata.Load(getVersionDir(version))
+	return err == nil && m.Link != ""
+}
+
+// Find the linked version containing the docker client, e.g. when it is the current docker
+func getLinkedVersion(dockerPath string) (dockerversion.Version, bool) {
+	versionDir := filepath.Dir(dockerPath)
+	if filepath.Dir(versionDir) != getVersionsDir() {
+		return dockerversion.Version{}, false
+	}
+
+	version := dockerversion.Parse(filepath.Base(versionDir))
+	return version, isLinkedVersion(version)
+}
+
+// Describe a linked version with the version reported by its client, e.g. my-patched (20.10.24)
+func formatLinkedVersion(version dockerversion.Version) string {
+	m, err := metadata.Load(getVersionDir(version))
+	if err != nil || m.Client.Version == "" {
+		return version.String()
+	}
+	return version.Name() + " (" + m.Client.Version + ")"
+}
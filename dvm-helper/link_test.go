@@ -0,0 +1,33 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
+	"github.com/howtowhale/dvm/dvm-helper/internal/metadata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetLinkedVersion(t *testing.T) {
+	dvmDir := setupTestDvmDir(t)
+
+	linked := dockerversion.Parse("my-patched")
+	m := metadata.Install{Version: "my-patched", InstalledAt: time.Now(), Link: "/opt/docker/bin/docker"}
+	require.NoError(t, m.Save(getVersionDir(linked)))
+
+	installed := dockerversion.Parse("20.10.24")
+	require.NoError(t, metadata.Install{Version: "20.10.24", InstalledAt: time.Now()}.Save(getVersionDir(installed)))
+
+	version, ok := getLinkedVersion(filepath.Join(getVersionDir(linked), getBinaryName()))
+	assert.True(t, ok, "my-patched should be a linked version")
+	assert.Equal(t, "my-patched", version.Name())
+
+	_, ok = getLinkedVersion(filepath.Join(getVersionDir(installed), getBinaryName()))
+	assert.False(t, ok, "An installed version should not be a linked version")
+
+	_, ok = getLinkedVersion(filepath.Join(dvmDir, "my-patched", getBinaryName()))
+	assert.False(t, ok, "A docker outside the versions directory should not be a linked version")
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
+	"github.com/howtowhale/dvm/dvm-helper/internal/downloader"
+	"github.com/pkg/errors"
+)
+
+// Matches release file names such as docker-20.10.24.tgz or docker-1.10.3.exe
+var releaseFileRegex = regexp.MustCompile(`^docker-(\d.*?)(\.tgz|\.tar\.gz|\.zip|\.exe)?$`)
+
+// Install a Docker version from a local archive or binary, e.g. for air-gapped machines
+// filePath - location of a release archive or a docker client binary
+// value - optional version, otherwise it is derived from the file name or the binary
+// checksumPath - optional SHA256 checksum file, defaults to <filePath>.sha256 when present
+func installFromFile(filePath string, value string, checksumPath string) {
+	if _, err := os.Stat(filePath); err != nil {
+		die("Unable to read %s.", err, retCodeInvalidArgument, filePath)
+	}
+
+	lockDvmDir()
+	d := downloader.New(opts)
+
+	if checksumPath == "" {
+		if _, err := os.Stat(filePath + ".sha256"); err == nil {
+			checksumPath = filePath + ".sha256"
+		}
+	}
+	if checksumPath != "" {
+		if err := d.VerifyChecksum(filePath, checksumPath); err != nil {
+			die("", err, retCodeRuntimeError)
+		}
+		writeInfo("Verified the checksum of %s", filepath.Base(filePath))
+	}
+
+	if value == "" {
+		value = parseReleaseFileVersion(filePath)
+	}
+
+	// Unpack the client into a staging directory until we know which version it is
+	stagingDir := newStagingDir()
+	defer os.RemoveAll(stagingDir)
+	tmpPath := filepath.Join(stagingDir, "from-file", getBinaryName())
+	err := extractDockerBinary(d, filePath, tmpPath)
+	if err != nil {
+		die("Unable to extract the Docker client from %s.", err, retCodeRuntimeError, filePath)
+	}
+
+	if value == "" {
+		info, err := inspectDockerClient(tmpPath)
+		if err != nil {
+			die("Unable to determine the version of %s, specify it with --version.", err, retCodeInvalidArgument, filePath)
+		}
+		value = info.Version
+		writeDebug("Detected Docker %s in %s", value, filePath)
+	}
+
+	version := dockerversion.Parse(value)
+	if version.IsAlias() || version.IsEdge() || version.IsSystem() {
+		die("%s is not a valid version to install from a file.", nil, retCodeInvalidArgument, value)
+	}
+
+	versionDir := getVersionDir(version)
+	if _, err := os.Stat(versionDir); err == nil {
+		writeWarning("%s is already installed", version)
+		use(version)
+		return
+	}
+
+	writeInfo("Installing %s from %s...", version, filePath)
+
+	stagedVersionDir := filepath.Join(stagingDir, filepath.Base(versionDir))
+	if err = os.Rename(filepath.Dir(tmpPath), stagedVersionDir); err != nil {
+		die("Unable to move %s to %s.", err, retCodeRuntimeError, tmpPath, stagedVersionDir)
+	}
+
+	validateRelease(version, stagedVersionDir)
+	saveInstallMetadata(version, stagedVersionDir)
+	commitStagedVersion(version, stagedVersionDir)
+
+	if useAfterInstall {
+		use(version)
+	}
+}
+
+// Derive the version from a release file name, returning an empty string when it doesn't match
+func parseReleaseFileVersion(filePath string) string {
+	match := releaseFileRegex.FindStringSubmatch(filepath.Base(filePath))
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// Save the docker client binary to destPath, extracting it first when filePath is an archive
+func extractDockerBinary(d downloader.Client, filePath string, destPath string) error {
+	archived, err := isArchive(filePath)
+	if err != nil {
+		return err
+	}
+
+	if archived {
+		return d.ExtractArchivedFile(filePath, filepath.Join("docker", getBinaryName()), destPath)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(destPath), os.ModePerm); err != nil {
+		return errors.Wrapf(err, "Unable to create parent directory %s", destPath)
+	}
+	return copyFile(filePath, destPath, 0755)
+}
+
+// Check for the gzip or zip magic numbers at the start of a file
+func isArchive(filePath string) (bool, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return false, errors.Wrapf(err, "Unable to open %s", filePath)
+	}
+	defer f.Close()
+
+	header := make([]byte, 4)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, errors.Wrapf(err, "Unable to read %s", filePath)
+	}
+	header = header[:n]
+
+	return bytes.HasPrefix(header, []byte{0x1f, 0x8b}) || bytes.HasPrefix(header, []byte("PK\x03\x04")), nil
+}
+
+func copyFile(srcPath string, destPath string, mode os.FileMode) error {
+	contents, err := ioutil.ReadFile(srcPath)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to read %s", srcPath)
+	}
+	err = ioutil.WriteFile(destPath, contents, mode)
+	return errors.Wrapf(err, "Unable to write %s", destPath)
+}
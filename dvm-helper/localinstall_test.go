@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReleaseFileVersion(t *testing.T) {
+	testcases := map[string]string{
+		"docker-20.10.24.tgz":         "20.10.24",
+		"docker-17.09.0-ce.tgz":       "17.09.0-ce",
+		"docker-17.10.0-ce-rc1.zip":   "17.10.0-ce-rc1",
+		"docker-1.10.3.exe":           "1.10.3",
+		"docker-1.9.1":                "1.9.1",
+		"docker-20.10.24.tar.gz":      "20.10.24",
+		"/mnt/usb/docker-19.03.1.tgz": "19.03.1",
+		"docker.tgz":                  "",
+		"docker-latest.tgz":           "",
+		"mystery.tgz":                 "",
+	}
+
+	for file, want := range testcases {
+		assert.Equal(t, want, parseReleaseFileVersion(file), file)
+	}
+}
+
+func TestIsArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dvmtest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	testcases := map[string]struct {
+		contents string
+		want     bool
+	}{
+		"gzip":   {contents: "\x1f\x8b\x08\x00", want: true},
+		"zip":    {contents: "PK\x03\x04rest", want: true},
+		"elf":    {contents: "\x7fELF\x02\x01", want: false},
+		"script": {contents: "#!", want: false},
+		"empty":  {contents: "", want: false},
+	}
+
+	for name, tc := range testcases {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte(tc.contents), 0644))
+
+		got, err := isArchive(path)
+		require.NoError(t, err, name)
+		assert.Equal(t, tc.want, got, name)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	neturl "net/url"
+	"path/filepath"
+
+	"github.com/codegangsta/cli"
+	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
+	"github.com/howtowhale/dvm/dvm-helper/internal/httpclient"
+	"github.com/howtowhale/dvm/dvm-helper/internal/mirror"
+)
+
+// The file listing the mirrors to download from, one per line
+func getMirrorsPath() string {
+	return filepath.Join(opts.DvmDir, "mirrors")
+}
+
+// The file where mirrors that recently failed are remembered between commands
+func getMirrorStatePath() string {
+	return filepath.Join(opts.DvmDir, ".tmp", "mirrors.json")
+}
+
+// Find the mirrors to download from, preferring --mirror-url, then --mirrors,
+// then the mirrors file, and finally docker.com.
+func getMirrors(c *cli.Context) ([]string, error) {
+	if mirrorURL := c.String("mirror-url"); mirrorURL != "" {
+		return []string{mirrorURL}, nil
+	}
+
+	if mirrors := mirror.Parse(c.GlobalString("mirrors")); len(mirrors) > 0 {
+		return mirrors, nil
+	}
+
+	mirrorsPath := getMirrorsPath()
+	mirrors, err := mirror.ReadFile(mirrorsPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(mirrors) > 0 {
+		writeDebug("Using the mirrors listed in %s", mirrorsPath)
+		return mirrors, nil
+	}
+
+	return []string{""}, nil
+}
+
+// Split the credentials from the mirror URLs, which are preferred over a token,
+// then a username and password, and finally the netrc file.
+func buildMirrorAuth(c *cli.Context, mirrors []string) ([]string, httpclient.MirrorAuth, error) {
+	auth := httpclient.MirrorAuth{
+		Token:        c.GlobalString("mirror-token"),
+		Username:     c.GlobalString("mirror-user"),
+		Password:     c.GlobalString("mirror-password"),
+		NetrcFile:    c.GlobalString("netrc-file"),
+		NetrcDefault: c.GlobalBool("netrc-default"),
+	}
+	if auth.NetrcFile == "" {
+		auth.NetrcFile = filepath.Join(getUserHomeDir(), netrcFileName)
+	}
+
+	var results []string
+	for _, mirrorURL := range mirrors {
+		// Credentials are never sent to docker.com, only to the configured mirrors
+		if mirrorURL == "" {
+			results = append(results, mirrorURL)
+			continue
+		}
+
+		mirrorURL, user, err := httpclient.SplitMirrorCredentials(mirrorURL)
+		if err != nil {
+			return nil, httpclient.MirrorAuth{}, err
+		}
+
+		if _, err := mirror.ParseLocation(mirrorURL); err != nil {
+			return nil, httpclient.MirrorAuth{}, err
+		}
+
+		host := httpclient.MirrorHost(mirrorURL)
+		auth.Hosts = append(auth.Hosts, host)
+		if user != nil {
+			if auth.Logins == nil {
+				auth.Logins = make(map[string]*neturl.Userinfo)
+			}
+			auth.Logins[host] = user
+			writeDebug("Authenticating with %s as %s, using the credentials in the mirror URL", mirrorURL, user.Username())
+		}
+		results = append(results, mirrorURL)
+	}
+
+	switch {
+	case len(auth.Hosts) == 0 && (auth.Token != "" || auth.Username != ""):
+		writeWarning("Ignoring the mirror credentials, they are only sent to the configured mirrors and never to docker.com")
+	case auth.Token != "":
+		writeDebug("Authenticating with the mirrors using a bearer token")
+	case auth.Username != "":
+		writeDebug("Authenticating with the mirrors as %s", auth.Username)
+	}
+
+	return results, auth, nil
+}
+
+// Tell the user which mirror served a download, when there was a choice
+func reportMirror(served dockerversion.DownloadCandidate) {
+	if opts.Mirrors != nil && opts.Mirrors.Len() > 1 {
+		writeInfo("Downloaded %s", served.URL)
+		return
+	}
+	writeDebug("Downloaded %s", served.URL)
+}
@@ -1,9 +1,8 @@
 package main
 
 import (
-	"fmt"
 	"os"
-	"regexp"
+	"strings"
 )
 
 const pathEnvVar string = "PATH"
@@ -18,17 +17,51 @@ func setPath(value string) {
 	os.Setenv(pathEnvVar, value)
 }
 
-// Prepend the specified value to the PATH environment variable
+// Split a PATH value into its entries
+func splitPath(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, string(os.PathListSeparator))
+}
+
+// Join entries into a PATH value
+func joinPath(entries []string) string {
+	return strings.Join(entries, string(os.PathListSeparator))
+}
+
+// Prepend the specified value to the PATH environment variable,
+// removing any other occurrences of it
 func prependPath(value string) {
-	originalPath := getPath()
-	newPath := fmt.Sprintf("%s%c%s", value, os.PathListSeparator, originalPath)
-	setPath(newPath)
+	entries := append([]string{value}, splitPath(getPath())...)
+	setPath(joinPath(filterPath(entries, func(string) bool { return false })))
 }
 
-// Remove any values which match the specified regular expression
+// Remove any entries for which shouldRemove returns true
 // from the PATH environment variable
-func removePath(regexValue string) {
-	regex, _ := regexp.Compile(regexValue)
-	newPath := regex.ReplaceAllString(getPath(), "")
-	setPath(newPath)
+func removePath(shouldRemove func(entry string) bool) {
+	setPath(joinPath(filterPath(splitPath(getPath()), shouldRemove)))
+}
+
+// Filter PATH entries, dropping duplicates while preserving
+// the position of the first occurrence of each entry
+func filterPath(entries []string, shouldRemove func(entry string) bool) []string {
+	seen := make(map[string]bool, len(entries))
+	results := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		key := normalizePath(entry)
+		if seen[key] || shouldRemove(entry) {
+			continue
+		}
+		seen[key] = true
+		results = append(results, entry)
+	}
+	return results
+}
+
+// Check if the path is located inside the specified directory
+func isPathWithin(path string, dir string) bool {
+	path = normalizePath(path)
+	dir = normalizePath(dir)
+	return strings.HasPrefix(path, dir+string(os.PathSeparator))
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemovePreviousDockerVersionFromPath(t *testing.T) {
+	originalPath := getPath()
+	defer setPath(originalPath)
+
+	dvmDir := setupTestDvmDir(t)
+	versionsDir := getVersionsDir()
+	systemDir := filepath.Join(os.TempDir(), "bin")
+	otherDir := filepath.Join(dvmDir+"x", "bin")
+
+	testcases := map[string]struct {
+		path     []string
+		wantPath []string
+	}{
+		"first": {
+			path:     []string{filepath.Join(versionsDir, "1.12.1"), systemDir},
+			wantPath: []string{systemDir},
+		},
+		"last": {
+			path:     []string{systemDir, filepath.Join(versionsDir, "1.12.1")},
+			wantPath: []string{systemDir},
+		},
+		"unclean": {
+			path:     []string{systemDir, filepath.Join(versionsDir, "1.12.1") + string(os.PathSeparator), otherDir},
+			wantPath: []string{systemDir, otherDir},
+		},
+		"similar prefix": {
+			path:     []string{otherDir, systemDir},
+			wantPath: []string{otherDir, systemDir},
+		},
+		"duplicates": {
+			path:     []string{systemDir, filepath.Join(versionsDir, "1.12.1"), otherDir, systemDir + string(os.PathSeparator)},
+			wantPath: []string{systemDir, otherDir},
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			setPath(joinPath(testcase.path))
+			removePreviousDockerVersionFromPath()
+			assert.Equal(t, joinPath(testcase.wantPath), getPath())
+		})
+	}
+}
+
+func TestPrependPath(t *testing.T) {
+	originalPath := getPath()
+	defer setPath(originalPath)
+
+	systemDir := filepath.Join(os.TempDir(), "bin")
+	versionDir := filepath.Join(os.TempDir(), "dvm", "bin", "docker", "1.12.1")
+
+	setPath(joinPath([]string{systemDir, versionDir}))
+	prependPath(versionDir)
+	assert.Equal(t, joinPath([]string{versionDir, systemDir}), getPath(), "The prepended entry should not be duplicated")
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
+	"github.com/pkg/errors"
+)
+
+// profileEnvVar lists the variables exported from the profile of the active version,
+// so that they can be removed when switching versions
+const profileEnvVar string = "DVM_PROFILE"
+
+const dockerConfigEnvVar string = "DOCKER_CONFIG"
+
+var profileKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func envSet(value string, assignments []string) {
+	lockDvmDir()
+	name := dockerversion.Parse(value).Name()
+	profile := readProfile(name)
+
+	for _, assignment := range assignments {
+		key, value, err := parseAssignment(assignment)
+		if err != nil {
+			die("", err, retCodeInvalidArgument)
+		}
+
+		profile[key] = value
+		writeInfo("Set %s for %s.", key, name)
+	}
+
+	writeProfile(name, profile)
+}
+
+func envUnset(value string, keys []string) {
+	lockDvmDir()
+	name := dockerversion.Parse(value).Name()
+	profile := readProfile(name)
+
+	for _, key := range keys {
+		if _, ok := profile[key]; !ok {
+			writeWarning("%s is not set for %s.", key, name)
+			continue
+		}
+
+		delete(profile, key)
+		writeInfo("Unset %s for %s.", key, name)
+	}
+
+	writeProfile(name, profile)
+}
+
+func envList(value string) {
+	name := dockerversion.Parse(value).Name()
+	profile := readProfile(name)
+
+	for _, key := range sortedKeys(profile) {
+		writeInfo("\t%s=%s", key, profile[key])
+	}
+}
+
+// Split KEY=VALUE, validating that it can be saved in a profile
+func parseAssignment(assignment string) (string, string, error) {
+	parts := strings.SplitN(assignment, "=", 2)
+	if len(parts) != 2 {
+		return "", "", errors.Errorf("Invalid environment variable %s, expected KEY=VALUE.", assignment)
+	}
+	if err := checkProfileKey(parts[0]); err != nil {
+		return "", "", err
+	}
+	if strings.ContainsAny(parts[1], "\r\n") {
+		return "", "", errors.Errorf("The value of %s cannot contain a newline.", parts[0])
+	}
+	return parts[0], parts[1], nil
+}
+
+func checkProfileKey(key string) error {
+	if !profileKeyRegex.MatchString(key) {
+		return errors.Errorf("Invalid environment variable name %s.", key)
+	}
+
+	switch key {
+	case pathEnvVar, snapshotEnvVar, profileEnvVar:
+		return errors.Errorf("%s is managed by dvm and cannot be set in a profile.", key)
+	}
+	return nil
+}
+
+func getProfilePath(name string) string {
+	return filepath.Join(opts.DvmDir, "env", name)
+}
+
+func readProfile(name string) map[string]string {
+	profile := make(map[string]string)
+
+	profilePath := getProfilePath(name)
+	file, err := os.Open(profilePath)
+	if os.IsNotExist(err) {
+		return profile
+	}
+	if err != nil {
+		die("Unable to read the profile at %s.", err, retCodeRuntimeError, profilePath)
+	}
+	defer file.Close()
+
+	err = parseProfile(file, profile)
+	if err != nil {
+		die("Unable to read the profile at %s.", err, retCodeRuntimeError, profilePath)
+	}
+
+	return profile
+}
+
+func parseProfile(r io.Reader, profile map[string]string) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return errors.Errorf("Invalid line: %s", line)
+		}
+		profile[parts[0]] = parts[1]
+	}
+	return scanner.Err()
+}
+
+func writeProfile(name string, profile map[string]string) {
+	profilePath := getProfilePath(name)
+	if len(profile) == 0 {
+		if err := os.Remove(profilePath); err != nil && !os.IsNotExist(err) {
+			die("Unable to update the profile at %s.", err, retCodeRuntimeError, profilePath)
+		}
+		return
+	}
+
+	var contents string
+	for _, key := range sortedKeys(profile) {
+		contents += key + "=" + profile[key] + "\n"
+	}
+	replaceFile(profilePath, contents)
+}
+
+// Get the names of the profiles which apply to a version, from least to most specific
+func getProfileNames(version dockerversion.Version) []string {
+	var names []string
+	if slug := version.Slug(); slug != "" {
+		names = append(names, slug)
+	}
+	if name := version.Name(); name != version.Slug() {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Export the environment variables configured for a version, removing those of the previously used version
+func applyProfile(version dockerversion.Version) {
+	for _, key := range strings.Split(os.Getenv(profileEnvVar), ",") {
+		if key != "" {
+			restoreEnvironmentVariable(key)
+		}
+	}
+
+	profile := make(map[string]string)
+	for _, name := range getProfileNames(version) {
+		for key, value := range readProfile(name) {
+			profile[key] = value
+		}
+	}
+
+	if opts.IsolateConfig {
+		profile[dockerConfigEnvVar] = isolateDockerConfig(version)
+	}
+
+	if len(profile) == 0 {
+		restoreEnvironmentVariable(profileEnvVar)
+		return
+	}
+
+	keys := sortedKeys(profile)
+	for _, key := range keys {
+		writeDebug("Setting %s from the profile for %s", key, version)
+		setEnvironmentVariable(key, profile[key])
+	}
+	setEnvironmentVariable(profileEnvVar, strings.Join(keys, ","))
+}
+
+// Get a DOCKER_CONFIG directory dedicated to a version,
+// seeding it with the user's config.json the first time that it is used
+func isolateDockerConfig(version dockerversion.Version) string {
+	names := getProfileNames(version)
+	configDir := filepath.Join(opts.DvmDir, "config", names[len(names)-1])
+	userConfigDir := getUserDockerConfigDir()
+	if _, err := os.Stat(configDir); os.IsNotExist(err) {
+		seedDockerConfig(configDir, userConfigDir)
+	}
+	linkDockerContexts(configDir, userConfigDir)
+
+	return configDir
+}
+
+func seedDockerConfig(configDir string, userConfigDir string) {
+	err := os.MkdirAll(configDir, 0700)
+	if err != nil {
+		die("Unable to create the docker config directory %s.", err, retCodeRuntimeError, configDir)
+	}
+
+	userConfigPath := filepath.Join(userConfigDir, "config.json")
+	userConfig, err := ioutil.ReadFile(userConfigPath)
+	if err != nil {
+		writeDebug("Not seeding %s: %s", configDir, err)
+		return
+	}
+
+	err = ioutil.WriteFile(filepath.Join(configDir, "config.json"), userConfig, 0600)
+	if err != nil {
+		die("Unable to seed the docker config directory %s.", err, retCodeRuntimeError, configDir)
+	}
+	writeDebug("Seeded %s from %s", configDir, userConfigPath)
+}
+
+// Share the user's docker contexts with an isolated config directory,
+// so that the current context saved in the seeded config.json can still be found
+func linkDockerContexts(configDir string, userConfigDir string) {
+	linkPath := filepath.Join(configDir, "contexts")
+	if _, err := os.Lstat(linkPath); err == nil {
+		return
+	}
+
+	contextsDir := filepath.Join(userConfigDir, "contexts")
+	if _, err := os.Stat(contextsDir); err != nil {
+		writeDebug("Not linking the docker contexts into %s: %s", configDir, err)
+		return
+	}
+
+	if err := os.Symlink(contextsDir, linkPath); err != nil {
+		writeDebug("Unable to link the docker contexts into %s: %s", configDir, err)
+		return
+	}
+	writeDebug("Linked %s to %s", linkPath, contextsDir)
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
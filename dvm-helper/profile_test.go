@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAssignment(t *testing.T) {
+	testcases := []struct {
+		assignment string
+		wantKey    string
+		wantValue  string
+		wantErr    bool
+	}{
+		{"DOCKER_HOST=tcp://remote:2376", "DOCKER_HOST", "tcp://remote:2376", false},
+		{"_PRIVATE=a=b", "_PRIVATE", "a=b", false},
+		{"EMPTY=", "EMPTY", "", false},
+		{"NOVALUE", "", "", true},
+		{"=value", "", "", true},
+		{"1NUMBER=value", "", "", true},
+		{"HAS-DASH=value", "", "", true},
+		{"HAS SPACE=value", "", "", true},
+		{"MULTILINE=a\nb", "", "", true},
+		{pathEnvVar + "=/usr/bin", "", "", true},
+		{snapshotEnvVar + "=value", "", "", true},
+		{profileEnvVar + "=value", "", "", true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.assignment, func(t *testing.T) {
+			key, value, err := parseAssignment(tc.assignment)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantKey, key)
+			assert.Equal(t, tc.wantValue, value)
+		})
+	}
+}
+
+func TestEnvSetUnset(t *testing.T) {
+	setupTestDvmDir(t)
+	defer func() {
+		dvmDirLock.Release()
+		dvmDirLock = nil
+	}()
+
+	envSet("20.10.24", []string{"DOCKER_HOST=tcp://remote:2376", "DOCKER_TLS_VERIFY=1", "QUOTED=say \"hi\" = $HOME"})
+	assert.Equal(t, map[string]string{
+		"DOCKER_HOST":       "tcp://remote:2376",
+		"DOCKER_TLS_VERIFY": "1",
+		"QUOTED":            "say \"hi\" = $HOME",
+	}, readProfile("20.10.24"))
+
+	// Setting a variable again replaces its value
+	envSet("20.10.24", []string{"DOCKER_HOST=tcp://other:2376"})
+	assert.Equal(t, "tcp://other:2376", readProfile("20.10.24")["DOCKER_HOST"])
+
+	envUnset("20.10.24", []string{"DOCKER_HOST", "QUOTED", "MISSING"})
+	assert.Equal(t, map[string]string{"DOCKER_TLS_VERIFY": "1"}, readProfile("20.10.24"))
+
+	// Removing the last variable removes the profile
+	envUnset("20.10.24", []string{"DOCKER_TLS_VERIFY"})
+	assert.Empty(t, readProfile("20.10.24"))
+	_, err := os.Stat(getProfilePath("20.10.24"))
+	assert.True(t, os.IsNotExist(err), "An empty profile should be removed")
+}
+
+func TestIsolateDockerConfig_Detect(t *testing.T) {
+	setupEnvironmentTest(t)
+	defer func() {
+		opts.IsolateConfig = false
+		if dvmDirLock != nil {
+			dvmDirLock.Release()
+			dvmDirLock = nil
+		}
+	}()
+
+	// The user's config selects a context which is bound to a version
+	userConfigDir := t.TempDir()
+	t.Setenv(dockerConfigEnvVar, userConfigDir)
+	t.Setenv("DOCKER_CONTEXT", "")
+	t.Setenv("DOCKER_HOST", "")
+	os.Unsetenv("DOCKER_CONTEXT")
+	os.Unsetenv("DOCKER_HOST")
+	metaDir := filepath.Join(userConfigDir, "contexts", "meta", fmt.Sprintf("%x", sha256.Sum256([]byte("remote"))))
+	require.NoError(t, os.MkdirAll(metaDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(metaDir, "meta.json"), []byte(`{"Name":"remote","Endpoints":{"docker":{"Host":"tcp://remote:2376"}}}`), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(userConfigDir, "config.json"), []byte(`{"currentContext":"remote"}`), 0644))
+	replaceFile(getBindingPath("remote"), "20.10.24")
+
+	opts.IsolateConfig = true
+	applyProfile(dockerversion.Parse("19.03.15"))
+	isolatedConfigDir := os.Getenv(dockerConfigEnvVar)
+	require.NotEqual(t, userConfigDir, isolatedConfigDir, "DOCKER_CONFIG should point at the isolated directory")
+
+	outputCapture := &bytes.Buffer{}
+	oldOutput := color.Output
+	color.Output = outputCapture
+	defer func() { color.Output = oldOutput }()
+
+	detect(detectOptions{Print: true})
+	assert.Contains(t, outputCapture.String(), "20.10.24", "The context should be found through the linked contexts")
+
+	// Without the link, e.g. when symlinks are not permitted, the user's contexts are used
+	require.NoError(t, os.Remove(filepath.Join(isolatedConfigDir, "contexts")))
+	outputCapture.Reset()
+	detect(detectOptions{Print: true})
+	assert.Contains(t, outputCapture.String(), "20.10.24", "The context should be found in the user's config directory")
+}
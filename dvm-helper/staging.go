@@ -0,0 +1,102 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
+	"github.com/howtowhale/dvm/dvm-helper/internal/lock"
+)
+
+// The lock held on DVM_DIR by this process, see lockDvmDir
+var dvmDirLock *lock.Lock
+
+// Take an advisory lock on DVM_DIR before modifying it, so that concurrent dvm commands run one at a time.
+// The lock is held until dvm exits, and calling this again while it is held is a no-op.
+func lockDvmDir() {
+	lockPath := filepath.Join(opts.DvmDir, ".lock")
+	if dvmDirLock != nil {
+		if dvmDirLock.Path() == lockPath {
+			return
+		}
+		dvmDirLock.Release()
+		dvmDirLock = nil
+	}
+
+	l, err := lock.Acquire(appCtx, lockPath, func() {
+		writeInfo("Waiting for another dvm command to finish...")
+	})
+	if err != nil {
+		die("Unable to lock %s.", err, retCodeRuntimeError, opts.DvmDir)
+	}
+	dvmDirLock = l
+	writeDebug("Locked %s", lockPath)
+
+	removeStaleStagingDirs()
+}
+
+func getStagingRoot() string {
+	return filepath.Join(opts.DvmDir, ".tmp", "staging")
+}
+
+// Create a unique directory for assembling an install before it is moved into place.
+// Callers must hold the DVM_DIR lock.
+func newStagingDir() string {
+	stagingRoot := getStagingRoot()
+	if err := os.MkdirAll(stagingRoot, os.ModePerm); err != nil {
+		die("Unable to create %s.", err, retCodeRuntimeError, stagingRoot)
+	}
+
+	stagingDir, err := ioutil.TempDir(stagingRoot, "install-")
+	if err != nil {
+		die("Unable to create a temporary directory in %s.", err, retCodeRuntimeError, stagingRoot)
+	}
+	onExit(func() { os.RemoveAll(stagingDir) })
+	return stagingDir
+}
+
+// Staging directories are only created while holding the DVM_DIR lock,
+// so any found after taking the lock were left behind by an interrupted command.
+func removeStaleStagingDirs() {
+	stagingDirs, _ := ioutil.ReadDir(getStagingRoot())
+	for _, stagingDir := range stagingDirs {
+		stagingPath := filepath.Join(getStagingRoot(), stagingDir.Name())
+		writeDebug("Removing stale staging directory %s", stagingPath)
+		if err := os.RemoveAll(stagingPath); err != nil {
+			writeWarning("Unable to remove %s: %s", stagingPath, err)
+		}
+	}
+}
+
+// Move a fully assembled and validated version directory into place.
+// An existing version directory, e.g. edge, is only removed once it has been replaced.
+func commitStagedVersion(version dockerversion.Version, stagedVersionDir string) {
+	versionDir := getVersionDir(version)
+	if err := os.MkdirAll(filepath.Dir(versionDir), os.ModePerm); err != nil {
+		die("Unable to create %s.", err, retCodeRuntimeError, filepath.Dir(versionDir))
+	}
+
+	replacedDir := stagedVersionDir + ".replaced"
+	_, err := os.Stat(versionDir)
+	replacing := err == nil
+	if replacing {
+		if err = os.Rename(versionDir, replacedDir); err != nil {
+			die("Unable to replace %s.", err, retCodeRuntimeError, versionDir)
+		}
+	}
+
+	if err = os.Rename(stagedVersionDir, versionDir); err != nil {
+		if replacing {
+			os.Rename(replacedDir, versionDir)
+		}
+		die("Unable to move %s to %s.", err, retCodeRuntimeError, stagedVersionDir, versionDir)
+	}
+
+	if replacing {
+		if err = os.RemoveAll(replacedDir); err != nil {
+			writeWarning("Unable to remove %s: %s", replacedDir, err)
+		}
+	}
+	writeDebug("Installed Docker %s to %s", version, versionDir)
+}
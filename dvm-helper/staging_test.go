@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockDvmDir_RemovesStaleStagingDirs(t *testing.T) {
+	setupTestDvmDir(t)
+
+	stalePath := filepath.Join(getStagingRoot(), "install-123", "20.10.24", "docker")
+	require.NoError(t, os.MkdirAll(filepath.Dir(stalePath), 0755))
+	require.NoError(t, ioutil.WriteFile(stalePath, []byte("partial"), 0755))
+
+	lockDvmDir()
+	defer func() {
+		dvmDirLock.Release()
+		dvmDirLock = nil
+	}()
+
+	_, err := os.Stat(filepath.Join(getStagingRoot(), "install-123"))
+	assert.True(t, os.IsNotExist(err), "Staging directories left by an interrupted command should be removed")
+
+	// Taking the lock again is a no-op, so this process's own staging directories are kept
+	stagingDir := newStagingDir()
+	lockDvmDir()
+	_, err = os.Stat(stagingDir)
+	assert.NoError(t, err)
+}
+
+func TestCommitStagedVersion(t *testing.T) {
+	setupTestDvmDir(t)
+
+	version := dockerversion.Parse("edge")
+	versionDir := getVersionDir(version)
+	require.NoError(t, os.MkdirAll(versionDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(versionDir, "docker"), []byte("old"), 0755))
+
+	stagingDir := newStagingDir()
+	stagedVersionDir := filepath.Join(stagingDir, filepath.Base(versionDir))
+	require.NoError(t, os.MkdirAll(stagedVersionDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(stagedVersionDir, "docker"), []byte("new"), 0755))
+
+	commitStagedVersion(version, stagedVersionDir)
+
+	contents, err := ioutil.ReadFile(filepath.Join(versionDir, "docker"))
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(contents), "The existing version should be replaced")
+
+	leftovers, _ := ioutil.ReadDir(stagingDir)
+	assert.Empty(t, leftovers, "The replaced version should be removed")
+}
@@ -5,23 +5,45 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/fatih/color"
 )
 
 func exportEnvironmentVariable(name string) string {
-	value := os.Getenv(name)
+	value, isSet := os.LookupEnv(name)
+	if !isSet {
+		return unsetEnvironmentVariableStatement(name)
+	}
+
+	if opts.Shell == "powershell" {
+		return fmt.Sprintf("$env:%s=\"%s\"\r\n", name, powershellEscaper.Replace(value))
+	}
+
+	if opts.Shell == "cmd" {
+		return fmt.Sprintf("%s=%s\r\n", name, cmdEscaper.Replace(value))
+	}
+
+	// default to bash
+	return fmt.Sprintf("export %s=\"%s\"\n", name, shEscaper.Replace(value))
+}
+
+// Escape the characters which are special inside a double quoted string
+var shEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+var powershellEscaper = strings.NewReplacer("`", "``", `"`, "`\"", "$", "`$")
+var cmdEscaper = strings.NewReplacer("%", "%%")
 
+func unsetEnvironmentVariableStatement(name string) string {
 	if opts.Shell == "powershell" {
-		return fmt.Sprintf("$env:%s=\"%s\"\r\n", name, value)
+		return fmt.Sprintf("Remove-Item Env:\\%s -ErrorAction SilentlyContinue\r\n", name)
 	}
 
 	if opts.Shell == "cmd" {
-		return fmt.Sprintf("%s=%s\r\n", name, value)
+		return fmt.Sprintf("%s=\r\n", name)
 	}
 
 	// default to bash
-	return fmt.Sprintf("export %s=\"%s\"\n", name, value)
+	return fmt.Sprintf("unset %s\n", name)
 }
 
 func ensureParentDirectoryExists(filePath string) {
@@ -33,13 +55,30 @@ func ensureParentDirectoryExists(filePath string) {
 	}
 }
 
+// Append to a file, creating it if needed, e.g. the output script collects every variable that dvm changes
 func writeFile(path string, contents string) {
+	writeFileWithFlag(path, contents, os.O_APPEND)
+}
+
+// Replace the contents of a file, creating it if needed. The new contents are written
+// next to the file and then renamed over it, so readers never see a partial file.
+func replaceFile(path string, contents string) {
+	tmpPath := path + ".tmp"
+	writeFileWithFlag(tmpPath, contents, os.O_TRUNC)
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		die("Unable to replace %s", err, retCodeRuntimeError, path)
+	}
+}
+
+func writeFileWithFlag(path string, contents string, flag int) {
 	writeDebug("Writing to %s...", path)
 	writeDebug(contents)
 
 	ensureParentDirectoryExists(path)
 
-	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0660)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|flag, 0660)
 	if err != nil {
 		die("Unable to create %s", err, retCodeRuntimeError, path)
 	}
@@ -49,7 +88,10 @@ func writeFile(path string, contents string) {
 		die("Unable to write to %s", err, retCodeRuntimeError, path)
 	}
 
-	file.Close()
+	err = file.Close()
+	if err != nil {
+		die("Unable to write to %s", err, retCodeRuntimeError, path)
+	}
 }
 
 func writeDebug(format string, a ...interface{}) {
@@ -86,6 +128,18 @@ func writeError(format string, err error, a ...interface{}) {
 }
 
 func die(format string, err error, exitCode int, a ...interface{}) {
+	runExitHandlers()
+
+	// Errors caused by cancelling are just noise, e.g. "context canceled"
+	if appCtx.Err() != nil {
+		writeDebug(format, a...)
+		if err != nil {
+			writeDebug("%s", err)
+		}
+		writeError("Cancelled.", nil)
+		os.Exit(retCodeCancelled)
+	}
+
 	writeError(format, err, a...)
 	os.Exit(exitCode)
 }
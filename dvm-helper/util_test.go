@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportEnvironmentVariable(t *testing.T) {
+	oldShell := opts.Shell
+	defer func() { opts.Shell = oldShell }()
+
+	t.Setenv("DVM_TEST_VALUE", "a \"quoted\" $HOME `cmd` \\ 100%")
+
+	testcases := []struct {
+		shell string
+		want  string
+	}{
+		{"sh", "export DVM_TEST_VALUE=\"a \\\"quoted\\\" \\$HOME \\`cmd\\` \\\\ 100%\"\n"},
+		{"powershell", "$env:DVM_TEST_VALUE=\"a `\"quoted`\" `$HOME ``cmd`` \\ 100%\"\r\n"},
+		{"cmd", "DVM_TEST_VALUE=a \"quoted\" $HOME `cmd` \\ 100%%\r\n"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.shell, func(t *testing.T) {
+			opts.Shell = tc.shell
+			assert.Equal(t, tc.want, exportEnvironmentVariable("DVM_TEST_VALUE"))
+		})
+	}
+}
+
+func TestExportEnvironmentVariable_Unset(t *testing.T) {
+	oldShell := opts.Shell
+	defer func() { opts.Shell = oldShell }()
+
+	t.Setenv("DVM_TEST_VALUE", "")
+	os.Unsetenv("DVM_TEST_VALUE")
+
+	opts.Shell = "sh"
+	assert.Equal(t, "unset DVM_TEST_VALUE\n", exportEnvironmentVariable("DVM_TEST_VALUE"))
+	opts.Shell = "powershell"
+	assert.Equal(t, "Remove-Item Env:\\DVM_TEST_VALUE -ErrorAction SilentlyContinue\r\n", exportEnvironmentVariable("DVM_TEST_VALUE"))
+	opts.Shell = "cmd"
+	assert.Equal(t, "DVM_TEST_VALUE=\r\n", exportEnvironmentVariable("DVM_TEST_VALUE"))
+}
+
+// The exported value should survive being evaluated by the shell unchanged
+func TestExportEnvironmentVariable_ShRoundTrip(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available")
+	}
+
+	oldShell := opts.Shell
+	defer func() { opts.Shell = oldShell }()
+	opts.Shell = "sh"
+
+	value := "a \"quoted\" $HOME `echo injected` \\n 'single' 100%"
+	t.Setenv("DVM_TEST_VALUE", value)
+	script := exportEnvironmentVariable("DVM_TEST_VALUE")
+
+	output, err := exec.Command("sh", "-c", script+`printf '%s' "$DVM_TEST_VALUE"`).Output()
+	require.NoError(t, err)
+	assert.Equal(t, value, string(output))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/howtowhale/dvm/dvm-helper/dockerversion"
+	"github.com/howtowhale/dvm/dvm-helper/internal/executable"
+	"github.com/pkg/errors"
+)
+
+// How long the smoke test of a downloaded client may run
+const smokeTestTimeout = 30 * time.Second
+
+// Check that a downloaded client is runnable, removing the install when it isn't
+func validateRelease(version dockerversion.Version, versionDir string) {
+	binaryPath := filepath.Join(versionDir, getBinaryName())
+
+	err := validateDockerBinary(binaryPath, opts.Platform.OS, opts.Platform.GOARCH())
+	if err != nil {
+		if removeErr := os.RemoveAll(versionDir); removeErr != nil {
+			writeWarning("Unable to remove %s: %s", versionDir, removeErr)
+		}
+		die("Docker %s failed validation and was not installed.", err, retCodeRuntimeError, version)
+	}
+
+	writeDebug("Validated %s", binaryPath)
+}
+
+// Check that the file is an executable for the platform, and when it targets
+// the current platform, that it runs
+func validateDockerBinary(path string, goos string, goarch string) error {
+	err := executable.Validate(path, goos, goarch)
+	if err != nil {
+		return err
+	}
+
+	if goos != runtime.GOOS || goarch != runtime.GOARCH {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(appCtx, smokeTestTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, path, "--version").CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "%s --version failed: %s", path, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
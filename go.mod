@@ -8,15 +8,15 @@ require (
 	github.com/docker/docker v1.13.1
 	github.com/fatih/color v1.5.0
 	github.com/google/go-github v0.0.0-20160619221136-1c08387e4c91
-	github.com/pivotal-golang/archiver v0.0.0-20170206191640-59f15fd17404
 	github.com/pkg/errors v0.8.1-0.20161029093637-248dadf4e906
 	github.com/ryanuber/go-glob v0.0.0-20170128012129-256dc444b735
 	github.com/stretchr/testify v1.1.4
+	golang.org/x/net v0.0.0-20201202161906-c7110b5ffcbb
 	golang.org/x/oauth2 v0.0.0-20170214231824-b9780ec78894
+	golang.org/x/sys v0.0.0-20210112080510-489259a85091
 )
 
 require (
-	code.cloudfoundry.org/archiver v0.0.0-20200131002800-4ca7245c29b1 // indirect
 	github.com/Microsoft/go-winio v0.3.8 // indirect
 	github.com/Sirupsen/logrus v0.11.3-0.20170215164324-7f4b1adc7917 // indirect
 	github.com/cyphar/filepath-securejoin v0.2.2 // indirect
@@ -32,8 +32,7 @@ require (
 	github.com/onsi/gomega v1.10.5 // indirect
 	github.com/opencontainers/go-digest v1.0.0-rc0 // indirect
 	github.com/pmezard/go-difflib v0.0.0-20151028094244-d8ed2627bdf0 // indirect
-	golang.org/x/net v0.0.0-20201202161906-c7110b5ffcbb // indirect
-	golang.org/x/sys v0.0.0-20210112080510-489259a85091 // indirect
+	golang.org/x/text v0.3.3 // indirect
 	google.golang.org/appengine v1.0.1-0.20170206203024-2e4a801b39fc // indirect
 	google.golang.org/protobuf v1.23.0 // indirect
 )